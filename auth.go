@@ -0,0 +1,163 @@
+package comfyui
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to outgoing requests. It is applied to
+// every HTTP request and to the WebSocket handshake, including redials.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// BearerTokenAuth sends a static bearer token in the Authorization header
+type BearerTokenAuth struct {
+	Token string
+}
+
+// Authenticate sets the Authorization header
+func (a BearerTokenAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// BasicAuth sends HTTP basic authentication credentials
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate sets the Authorization header
+func (a BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// HeaderAuth sends a credential in a custom header, e.g. an API key
+type HeaderAuth struct {
+	Name  string
+	Value string
+}
+
+// Authenticate sets the header
+func (a HeaderAuth) Authenticate(req *http.Request) error {
+	req.Header.Set(a.Name, a.Value)
+	return nil
+}
+
+// ComfyUserAuth selects the ComfyUI user (multi-user mode) via the comfy-user header
+func ComfyUserAuth(user string) HeaderAuth {
+	return HeaderAuth{Name: "comfy-user", Value: user}
+}
+
+// TokenSource supplies bearer tokens, e.g. from an OAuth provider
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceAuth sends a bearer token obtained from Source for each request
+type TokenSourceAuth struct {
+	Source TokenSource
+}
+
+// Authenticate fetches a token and sets the Authorization header
+func (a TokenSourceAuth) Authenticate(req *http.Request) error {
+	token, err := a.Source.Token(req.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// RefreshingTokenSource caches a token and fetches a new one shortly before
+// it expires. It is safe for concurrent use.
+type RefreshingTokenSource struct {
+	// Fetch returns a new token and its expiry. A zero expiry never expires.
+	Fetch func(ctx context.Context) (token string, expiry time.Time, err error)
+	// Leeway refreshes the token this long before it expires, defaults to 30s
+	Leeway time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewRefreshingTokenSource creates a token source that caches tokens returned by fetch
+func NewRefreshingTokenSource(fetch func(ctx context.Context) (string, time.Time, error)) *RefreshingTokenSource {
+	return &RefreshingTokenSource{Fetch: fetch}
+}
+
+// Token returns the cached token, fetching a new one if it is missing or about to expire
+func (s *RefreshingTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	leeway := s.Leeway
+	if leeway <= 0 {
+		leeway = 30 * time.Second
+	}
+	if s.token != "" && (s.expiry.IsZero() || time.Now().Add(leeway).Before(s.expiry)) {
+		return s.token, nil
+	}
+
+	token, expiry, err := s.Fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiry = token, expiry
+	return token, nil
+}
+
+// Invalidate drops the cached token so that the next request fetches a new one
+func (s *RefreshingTokenSource) Invalidate() {
+	s.mu.Lock()
+	s.token = ""
+	s.mu.Unlock()
+}
+
+// multiAuth applies several authenticators in order
+type multiAuth []Authenticator
+
+func (m multiAuth) Authenticate(req *http.Request) error {
+	for _, auth := range m {
+		if err := auth.Authenticate(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithAuth sets the authenticators applied to every request, e.g. a bearer
+// token for a reverse proxy together with ComfyUserAuth
+func WithAuth(auth ...Authenticator) Option {
+	return func(o *clientOptions) {
+		o.auth = append(o.auth, auth...)
+	}
+}
+
+// SetAuthenticator sets the authenticator applied to every request
+func (c *Client) SetAuthenticator(auth Authenticator) {
+	c.auth = auth
+}
+
+// authenticate adds the default headers and credentials to req
+func (c *Client) authenticate(req *http.Request) error {
+	for key, values := range c.header {
+		if _, ok := req.Header[key]; !ok {
+			req.Header[key] = append([]string(nil), values...)
+		}
+	}
+
+	if c.auth == nil {
+		return nil
+	}
+	if err := c.auth.Authenticate(req); err != nil {
+		return fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	return nil
+}
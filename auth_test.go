@@ -0,0 +1,139 @@
+package comfyui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAuthenticators(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   Authenticator
+		header string
+		want   string
+	}{
+		{"bearer", BearerTokenAuth{Token: "secret"}, "Authorization", "Bearer secret"},
+		{"basic", BasicAuth{Username: "user", Password: "pass"}, "Authorization", "Basic dXNlcjpwYXNz"},
+		{"header", HeaderAuth{Name: "X-API-Key", Value: "key"}, "X-API-Key", "key"},
+		{"comfy-user", ComfyUserAuth("alice"), "Comfy-User", "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if err := tt.auth.Authenticate(req); err != nil {
+				t.Fatalf("Authenticate failed: %v", err)
+			}
+			if got := req.Header.Get(tt.header); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthAppliedToEveryRequest(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string][2]string)
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = [2]string{r.Header.Get("Authorization"), r.Header.Get("comfy-user")}
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/ws":
+			if conn, err := upgrader.Upgrade(w, r, nil); err == nil {
+				conn.Close()
+			}
+		case "/upload/image":
+			w.Write([]byte(`{"name": "a.png", "subfolder": "", "type": "input"}`))
+		case "/view":
+			w.Write([]byte("image"))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithAuth(BearerTokenAuth{Token: "secret"}, ComfyUserAuth("alice")))
+	ctx := context.Background()
+
+	if _, err := client.GetHistory(ctx, ""); err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if _, err := client.UploadImageBytes(ctx, []byte("data"), "a.png", UploadOptions{}); err != nil {
+		t.Fatalf("UploadImageBytes failed: %v", err)
+	}
+	if _, err := client.GetImage(ctx, "a.png", "", "output"); err != nil {
+		t.Fatalf("GetImage failed: %v", err)
+	}
+	ws, err := client.ConnectWebSocket(ctx)
+	if err != nil {
+		t.Fatalf("ConnectWebSocket failed: %v", err)
+	}
+	ws.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/history", "/upload/image", "/view", "/ws"} {
+		if got := seen[path]; got != [2]string{"Bearer secret", "alice"} {
+			t.Errorf("%s: unexpected credentials %q", path, got)
+		}
+	}
+}
+
+func TestAuthenticatorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request should not be sent")
+	}))
+	defer server.Close()
+
+	failure := errors.New("token endpoint down")
+	source := NewRefreshingTokenSource(func(ctx context.Context) (string, time.Time, error) {
+		return "", time.Time{}, failure
+	})
+	client := NewClient(server.URL, WithAuth(TokenSourceAuth{Source: source}))
+
+	if _, err := client.GetQueue(context.Background()); !errors.Is(err, failure) {
+		t.Errorf("Expected token error, got %v", err)
+	}
+	if _, err := client.ConnectWebSocket(context.Background()); !errors.Is(err, failure) {
+		t.Errorf("Expected token error from ConnectWebSocket, got %v", err)
+	}
+}
+
+func TestRefreshingTokenSource(t *testing.T) {
+	fetches := 0
+	expiry := time.Now().Add(time.Hour)
+	source := NewRefreshingTokenSource(func(ctx context.Context) (string, time.Time, error) {
+		fetches++
+		return "token", expiry, nil
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if token, err := source.Token(ctx); err != nil || token != "token" {
+			t.Fatalf("Token() = %q, %v", token, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Expected the token to be cached, fetched %d times", fetches)
+	}
+
+	// Tokens about to expire are refreshed
+	expiry = time.Now().Add(10 * time.Second)
+	source.Invalidate()
+	source.Token(ctx)
+	source.Token(ctx)
+	if fetches != 3 {
+		t.Errorf("Expected a refresh within the leeway, fetched %d times", fetches)
+	}
+}
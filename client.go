@@ -4,76 +4,249 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client represents a ComfyUI API client
 type Client struct {
 	baseURL    string
+	base       *url.URL
+	baseErr    error
+	pathPrefix string
 	httpClient *http.Client
-	clientID   string
+	transport  http.RoundTripper
+	clientID   string // guarded by hubMu
+	header     http.Header
+	auth       Authenticator
+	dialer     *websocket.Dialer
+	logger     *slog.Logger
+	tracer     trace.Tracer
+	metrics    MetricsCollector
+	limiters   map[EndpointGroup]*limiter
+	completion CompletionStrategy
+	retry      RetryPolicy
+
+	requestInterceptors  []RequestInterceptor
+	responseInterceptors []ResponseInterceptor
+	messageInterceptor   MessageInterceptor
+
+	hubMu       sync.Mutex
+	hub         *eventHub
+	retiredHubs []*eventHub // hubs connected with a previous client ID
+
+	hubIdleTimeout time.Duration
 }
 
-// NewClient creates a new ComfyUI client
-func NewClient(baseURL string) *Client {
-	return &Client{
-		baseURL: strings.TrimSuffix(baseURL, "/"),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		clientID: uuid.New().String(),
+// NewClient creates a new ComfyUI client. Without options it uses a 30s
+// HTTP timeout, a random client ID and no retries. A base URL such as
+// unix:///run/comfyui.sock sends HTTP and WebSocket traffic over a Unix socket.
+//
+// Subscribe, Run and WaitForCompletion share one WebSocket connection that is
+// closed once it has been idle for 30s. Call Close to end any remaining
+// subscriptions and release the connection when the client is no longer used.
+func NewClient(baseURL string, opts ...Option) *Client {
+	o := clientOptions{header: make(http.Header)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.socketPath, baseURL = splitUnixBaseURL(baseURL)
+
+	clientID := o.clientID
+	if clientID == "" {
+		clientID = uuid.New().String()
+	}
+	logger := o.logger
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	httpClient := o.buildHTTPClient()
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	base, baseErr := parseBaseURL(baseURL)
+	pathPrefix := ""
+	if o.apiRoutes {
+		pathPrefix = apiPrefix
+	}
+
+	var auth Authenticator
+	switch len(o.auth) {
+	case 0:
+	case 1:
+		auth = o.auth[0]
+	default:
+		auth = multiAuth(o.auth)
 	}
-}
 
-// NewClientWithHTTPClient creates a new client with custom HTTP client
-func NewClientWithHTTPClient(baseURL string, httpClient *http.Client) *Client {
 	return &Client{
-		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		baseURL:    baseURL,
+		base:       base,
+		baseErr:    baseErr,
+		pathPrefix: pathPrefix,
 		httpClient: httpClient,
-		clientID:   uuid.New().String(),
+		transport:  o.buildTransport(httpClient),
+		clientID:   clientID,
+		header:     o.header,
+		auth:       auth,
+		dialer:     o.buildDialer(httpClient),
+		logger:     logger,
+		tracer:     o.buildTracer(),
+		metrics:    o.metrics,
+		limiters:   o.buildLimiters(),
+		completion: o.completion,
+		retry:      o.retry,
+
+		requestInterceptors:  o.requestInterceptors,
+		responseInterceptors: o.responseInterceptors,
+		messageInterceptor:   o.buildMessageInterceptor(),
+
+		hubIdleTimeout: hubIdleTimeout,
 	}
 }
 
-// SetClientID sets the client ID
+// NewClientWithHTTPClient creates a new client with custom HTTP client.
+// It is equivalent to NewClient(baseURL, WithHTTPClient(httpClient)).
+func NewClientWithHTTPClient(baseURL string, httpClient *http.Client) *Client {
+	return NewClient(baseURL, WithHTTPClient(httpClient))
+}
+
+// SetClientID sets the client ID. ComfyUI sends a prompt's events to the
+// connection of the client ID it was queued with, so later subscriptions use
+// a new shared connection, while existing ones keep the old connection until
+// they end.
 func (c *Client) SetClientID(clientID string) {
+	c.hubMu.Lock()
+	if clientID == c.clientID {
+		c.hubMu.Unlock()
+		return
+	}
 	c.clientID = clientID
+	hub := c.hub
+	c.hub = nil
+	if hub != nil {
+		c.retiredHubs = append(c.retiredHubs, hub)
+	}
+	c.hubMu.Unlock()
+
+	if hub != nil && hub.retire() {
+		c.releaseHub(hub)
+	}
 }
 
 // GetClientID returns the client ID
 func (c *Client) GetClientID() string {
+	c.hubMu.Lock()
+	defer c.hubMu.Unlock()
 	return c.clientID
 }
 
+// SetCompletionStrategy sets how Run and WaitForCompletion detect that a
+// prompt finished. The default is WebSocketCompletion.
+func (c *Client) SetCompletionStrategy(strategy CompletionStrategy) {
+	c.completion = strategy
+}
+
 // QueuePrompt queues a workflow for execution
 func (c *Client) QueuePrompt(ctx context.Context, workflow Workflow, extraData map[string]interface{}) (*QueuePromptResponse, error) {
-	req := QueuePromptRequest{
+	return c.queuePrompt(ctx, QueuePromptRequest{
 		Prompt:    workflow,
-		ClientID:  c.clientID,
+		ClientID:  c.GetClientID(),
 		ExtraData: extraData,
-	}
+	})
+}
 
-	var resp QueuePromptResponse
-	if err := c.doRequest(ctx, "POST", "/prompt", req, &resp); err != nil {
+// QueuePromptWithID queues a workflow under a caller-chosen prompt ID.
+// Knowing the ID in advance allows subscribing to its events before queueing.
+func (c *Client) QueuePromptWithID(ctx context.Context, promptID string, workflow Workflow, extraData map[string]interface{}) (*QueuePromptResponse, error) {
+	return c.queuePrompt(ctx, QueuePromptRequest{
+		Prompt:    workflow,
+		ClientID:  c.GetClientID(),
+		PromptID:  promptID,
+		ExtraData: extraData,
+	})
+}
+
+func (c *Client) queuePrompt(ctx context.Context, req QueuePromptRequest) (*QueuePromptResponse, error) {
+	// Replaying a prompt is only safe when its ID is fixed by the client
+	var resp *QueuePromptResponse
+	var err error
+	if req.PromptID != "" {
+		resp, err = c.postPromptWithRetry(ctx, req)
+	} else {
+		resp = &QueuePromptResponse{}
+		err = c.doRequest(ctx, "POST", "/prompt", req, resp)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to queue prompt: %w", err)
 	}
 
+	logger := c.log().With("prompt_id", resp.PromptID)
 	if len(resp.NodeErrors) > 0 {
-		return &resp, fmt.Errorf("node errors: %v", resp.NodeErrors)
+		err := nodeErrorsFromResponse(resp.NodeErrors)
+		logger.WarnContext(ctx, "prompt queued with node errors", "error", err)
+		return resp, fmt.Errorf("failed to queue prompt: %w", err)
 	}
 
+	logger.InfoContext(ctx, "prompt queued", "number", resp.Number)
+	return resp, nil
+}
+
+// postPromptWithRetry posts a prompt with a client-chosen ID, retrying
+// according to the RetryPolicy. ComfyUI queues a prompt again even if its ID
+// is already known, so before each replay it checks whether a failed attempt
+// reached the server after all, and then returns without resending.
+func (c *Client) postPromptWithRetry(ctx context.Context, req QueuePromptRequest) (*QueuePromptResponse, error) {
+	var resp QueuePromptResponse
+	known := false
+	post := func() error {
+		return c.doRequest(ctx, "POST", "/prompt", req, &resp)
+	}
+	_, err := c.withRetry(ctx, http.MethodPost, "/prompt", post, func() (bool, error) {
+		var lookupErr error
+		known, lookupErr = c.promptKnown(ctx, req.PromptID)
+		if lookupErr != nil {
+			// Without knowing whether the prompt arrived a replay could run it twice
+			c.log().DebugContext(ctx, "not retrying prompt", "prompt_id", req.PromptID, "error", lookupErr)
+			return false, nil
+		}
+		return !known, nil
+	})
+	if known {
+		c.log().DebugContext(ctx, "prompt already queued by a previous attempt", "prompt_id", req.PromptID)
+		return &QueuePromptResponse{PromptID: req.PromptID, NodeErrors: map[string]interface{}{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 	return &resp, nil
 }
 
+// promptKnown reports whether promptID is in the queue or the history
+func (c *Client) promptKnown(ctx context.Context, promptID string) (bool, error) {
+	queue, err := c.GetQueue(ctx)
+	if err != nil {
+		return false, err
+	}
+	if queue.contains(promptID) {
+		return true, nil
+	}
+	return c.hasHistory(ctx, promptID)
+}
+
 // QueuePromptFromFile loads a workflow from a JSON file and queues it for execution
 func (c *Client) QueuePromptFromFile(ctx context.Context, filepath string, extraData map[string]interface{}) (*QueuePromptResponse, error) {
 	workflow, err := LoadWorkflowFromFile(filepath)
@@ -96,19 +269,23 @@ func (c *Client) GetQueue(ctx context.Context) (*QueueStatus, error) {
 // ClearQueue clears all items from the queue
 func (c *Client) ClearQueue(ctx context.Context) error {
 	req := QueueManagementRequest{Clear: true}
-	return c.doRequest(ctx, "POST", "/queue", req, nil)
+	return c.doIdempotentRequest(ctx, "POST", "/queue", req, nil)
 }
 
 // DeleteFromQueue deletes specific items from the queue
 func (c *Client) DeleteFromQueue(ctx context.Context, promptIDs []string) error {
 	req := QueueManagementRequest{Delete: promptIDs}
-	return c.doRequest(ctx, "POST", "/queue", req, nil)
+	return c.doIdempotentRequest(ctx, "POST", "/queue", req, nil)
 }
 
 // Interrupt interrupts the current execution
 func (c *Client) Interrupt(ctx context.Context, promptID string) error {
 	req := InterruptRequest{PromptID: promptID}
-	return c.doRequest(ctx, "POST", "/interrupt", req, nil)
+	if promptID == "" {
+		// Without a prompt ID a replay could interrupt the next prompt
+		return c.doRequest(ctx, "POST", "/interrupt", req, nil)
+	}
+	return c.doIdempotentRequest(ctx, "POST", "/interrupt", req, nil)
 }
 
 // GetHistory retrieves execution history
@@ -129,13 +306,13 @@ func (c *Client) GetHistory(ctx context.Context, promptID string) (History, erro
 // ClearHistory clears all history
 func (c *Client) ClearHistory(ctx context.Context) error {
 	req := HistoryManagementRequest{Clear: true}
-	return c.doRequest(ctx, "POST", "/history", req, nil)
+	return c.doIdempotentRequest(ctx, "POST", "/history", req, nil)
 }
 
 // DeleteHistory deletes specific history items
 func (c *Client) DeleteHistory(ctx context.Context, promptIDs []string) error {
 	req := HistoryManagementRequest{Delete: promptIDs}
-	return c.doRequest(ctx, "POST", "/history", req, nil)
+	return c.doIdempotentRequest(ctx, "POST", "/history", req, nil)
 }
 
 // GetSystemStats retrieves system statistics
@@ -192,7 +369,7 @@ func (c *Client) FreeMemory(ctx context.Context, unloadModels, freeMemory bool)
 		UnloadModels: unloadModels,
 		FreeMemory:   freeMemory,
 	}
-	return c.doRequest(ctx, "POST", "/free", req, nil)
+	return c.doIdempotentRequest(ctx, "POST", "/free", req, nil)
 }
 
 // GetFeatures retrieves server features
@@ -250,26 +427,22 @@ func (c *Client) UploadImageBytes(ctx context.Context, data []byte, filename str
 
 	writer.Close()
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/upload/image", body)
+	req, err := c.newRequest(ctx, "POST", "/upload/image", nil, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := c.httpClient.Do(req)
+	// Without overwrite a replay would store a renamed duplicate
+	resp, err := c.send(req, opts.Overwrite)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload image: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	var uploadResp UploadImageResponse
 	if err := json.NewDecoder(resp.Body).Decode(&uploadResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("%w: failed to decode response: %w", ErrInvalidResponse, err)
 	}
 
 	return &uploadResp, nil
@@ -282,25 +455,24 @@ func (c *Client) GetImage(ctx context.Context, filename, subfolder, folderType s
 	params.Add("subfolder", subfolder)
 	params.Add("type", folderType)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/view?"+params.Encode(), nil)
+	req, err := c.newRequest(ctx, "GET", "/view", params, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.send(req, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get image: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get image: status %d", resp.StatusCode)
-	}
-
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read image data: %w", err)
 	}
+	if c.metrics != nil {
+		c.metrics.BytesDownloaded(int64(len(data)))
+	}
 
 	return data, nil
 }
@@ -325,63 +497,162 @@ func (c *Client) SaveImage(ctx context.Context, img ImageInfo, outputPath string
 	return nil
 }
 
-// WaitForCompletion waits for a workflow to complete and returns the results
-func (c *Client) WaitForCompletion(ctx context.Context, promptID string) (*ExecutionResult, error) {
-	ws, err := c.ConnectWebSocket(ctx)
+// RunOptions configures Client.Run
+type RunOptions struct {
+	// PromptID to queue the workflow under. A random ID is generated if empty.
+	PromptID  string
+	ExtraData map[string]interface{}
+	// Name identifies the workflow in metrics and traces
+	Name string
+}
+
+// Run queues a workflow and waits for it to complete. Watching starts before
+// the prompt is queued, so fast or fully cached workflows cannot finish
+// unnoticed. See WaitForCompletion for how failures are reported.
+//
+// Run requires a server that honours prompt_id; if the prompt is queued
+// under another ID, an error wrapping ErrInvalidResponse is returned.
+func (c *Client) Run(ctx context.Context, workflow Workflow, opts RunOptions) (result *ExecutionResult, err error) {
+	promptID := opts.PromptID
+	if promptID == "" {
+		promptID = uuid.New().String()
+	}
+
+	ctx, observer := c.observePrompt(ctx, promptID, opts.Name, workflow, true)
+	defer func() { observer.end(err) }()
+
+	watcher, err := c.completionStrategy().Watch(ctx, c, promptID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect websocket: %w", err)
+		return nil, err
 	}
-	defer ws.Close()
+	defer watcher.Close()
 
-	result := &ExecutionResult{
+	result = &ExecutionResult{
 		PromptID:  promptID,
 		StartTime: time.Now(),
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case msg, ok := <-ws.Messages():
-			if !ok {
-				return nil, fmt.Errorf("websocket closed unexpectedly")
-			}
+	resp, err := c.QueuePromptWithID(ctx, promptID, workflow, opts.ExtraData)
+	if err != nil {
+		return nil, err
+	}
+	observer.queued(resp)
 
-			if msg.Type == string(MessageTypeExecuting) {
-				data := msg.Data
-				if pid, ok := data["prompt_id"].(string); ok && pid == promptID {
-					if node, ok := data["node"].(string); !ok || node == "" {
-
-						// Execution completed
-						result.EndTime = time.Now()
-						result.Duration = result.EndTime.Sub(result.StartTime)
-
-						// Get history to retrieve outputs
-						history, err := c.GetHistory(ctx, promptID)
-						if err != nil {
-							return nil, fmt.Errorf("failed to get history: %w", err)
-						}
-
-						if item, ok := history[promptID]; ok {
-							result.Outputs = item.Outputs
-							result.Status = item.Status
-
-							// Collect all images
-							for _, output := range item.Outputs {
-								result.Images = append(result.Images, output.Images...)
-							}
-						}
-
-						return result, nil
-					}
-				}
-			}
-		}
+	// Servers that ignore prompt_id queue the prompt under their own ID,
+	// which the watcher would never see complete
+	if resp.PromptID != promptID {
+		return nil, fmt.Errorf("%w: prompt queued as %s instead of %s, the server may not support prompt_id",
+			ErrInvalidResponse, resp.PromptID, promptID)
 	}
+
+	return c.finishResult(ctx, result, watcher.Wait(ctx))
 }
 
-// doRequest performs an HTTP request
+// WaitForCompletion waits for a workflow to complete and returns the results.
+// Completion is detected by the client's CompletionStrategy, by default over
+// the shared WebSocket connection (see Subscribe). A prompt that already
+// finished is detected from history.
+//
+// If the prompt failed, the result is returned together with a *NodeError,
+// or with an error wrapping ErrInterrupted if it was interrupted.
+func (c *Client) WaitForCompletion(ctx context.Context, promptID string) (result *ExecutionResult, err error) {
+	ctx, observer := c.observePrompt(ctx, promptID, "", nil, false)
+	defer func() { observer.end(err) }()
+
+	watcher, err := c.completionStrategy().Watch(ctx, c, promptID)
+	if err != nil {
+		return nil, err
+	}
+	defer watcher.Close()
+
+	result = &ExecutionResult{
+		PromptID:  promptID,
+		StartTime: time.Now(),
+	}
+
+	// The prompt may have finished before watching started
+	if done, err := c.collectResult(ctx, result); err != nil {
+		return nil, err
+	} else if done {
+		return result, result.Status.ExecutionError()
+	}
+
+	return c.finishResult(ctx, result, watcher.Wait(ctx))
+}
+
+// finishResult collects the outputs and status of a prompt once waiting
+// ended with waitErr. Failed or interrupted prompts return the result
+// together with the execution error.
+func (c *Client) finishResult(ctx context.Context, result *ExecutionResult, waitErr error) (*ExecutionResult, error) {
+	if waitErr != nil && !errors.Is(waitErr, ErrExecutionFailed) && !errors.Is(waitErr, ErrInterrupted) {
+		return nil, waitErr
+	}
+
+	if _, err := c.collectResult(ctx, result); err != nil {
+		return nil, err
+	}
+
+	if waitErr == nil {
+		waitErr = result.Status.ExecutionError()
+	}
+
+	logger := c.log().With("prompt_id", result.PromptID, "duration", result.Duration)
+	if waitErr != nil {
+		logger.WarnContext(ctx, "prompt failed", "error", waitErr)
+	} else {
+		logger.InfoContext(ctx, "prompt finished", "outputs", len(result.Outputs))
+	}
+	return result, waitErr
+}
+
+// completionStrategy returns the configured strategy or the WebSocket default
+func (c *Client) completionStrategy() CompletionStrategy {
+	if c.completion == nil {
+		return WebSocketCompletion{}
+	}
+	return c.completion
+}
+
+// collectResult fills result from the prompt's history entry.
+// It reports false if the prompt has no history yet, i.e. it has not finished.
+func (c *Client) collectResult(ctx context.Context, result *ExecutionResult) (bool, error) {
+	// Get history to retrieve outputs
+	history, err := c.GetHistory(ctx, result.PromptID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	item, ok := history[result.PromptID]
+	if !ok {
+		return false, nil
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	result.Outputs = item.Outputs
+	result.Status = item.Status
+
+	// Collect all images
+	result.Images = nil
+	for _, output := range item.Outputs {
+		result.Images = append(result.Images, output.Images...)
+	}
+
+	return true, nil
+}
+
+// doRequest performs an HTTP request. Only GET requests are retried.
 func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}, result interface{}) error {
+	return c.doJSON(ctx, method, path, body, result, method == http.MethodGet)
+}
+
+// doIdempotentRequest performs an HTTP request that is safe to retry
+func (c *Client) doIdempotentRequest(ctx context.Context, method, path string, body interface{}, result interface{}) error {
+	return c.doJSON(ctx, method, path, body, result, true)
+}
+
+// doJSON performs an HTTP request with a JSON body and decodes the JSON response
+func (c *Client) doJSON(ctx context.Context, method, path string, body interface{}, result interface{}, idempotent bool) error {
 	var reqBody io.Reader
 	if body != nil {
 		jsonData, err := json.Marshal(body)
@@ -391,7 +662,7 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 		reqBody = bytes.NewReader(jsonData)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	req, err := c.newRequest(ctx, method, path, nil, reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -400,22 +671,170 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.send(req, idempotent)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	if result != nil {
 		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
-			return fmt.Errorf("failed to decode response: %w", err)
+			return fmt.Errorf("%w: failed to decode response: %w", ErrInvalidResponse, err)
 		}
 	}
 
 	return nil
 }
+
+// newRequest creates a request for path below the base URL
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u, err := c.endpoint(path, query)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// send performs req and returns the response if it has a 2xx status.
+// Otherwise the body is consumed and an *APIError is returned.
+// Idempotent requests are retried according to the client's RetryPolicy.
+func (c *Client) send(req *http.Request, idempotent bool) (*http.Response, error) {
+	req, span := c.startRequestSpan(req)
+	resp, attempts, err := c.sendWithRetry(req, idempotent)
+	endRequestSpan(span, resp, err, attempts)
+	return resp, err
+}
+
+// sendWithRetry performs req, retrying if it is idempotent. It also returns
+// the number of attempts made.
+func (c *Client) sendWithRetry(req *http.Request, idempotent bool) (*http.Response, int, error) {
+	var resp *http.Response
+	send := func() (err error) {
+		resp, err = c.sendOnce(req)
+		return err
+	}
+	if !idempotent || (req.Body != nil && req.GetBody == nil) {
+		if err := send(); err != nil {
+			return nil, 1, err
+		}
+		return resp, 1, nil
+	}
+
+	attempts, err := c.withRetry(req.Context(), req.Method, req.URL.Path, send, func() (bool, error) {
+		// Rewind the body for the next attempt
+		if req.GetBody != nil {
+			retryReq := req.Clone(req.Context())
+			body, err := req.GetBody()
+			if err != nil {
+				return false, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			retryReq.Body = body
+			req = retryReq
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, attempts, err
+	}
+	return resp, attempts, nil
+}
+
+// withRetry calls do until it succeeds or the RetryPolicy gives up, waiting
+// for the backoff between attempts. Before each retry next, if set, prepares
+// the following attempt; it returns false to stop with the last error. The
+// number of attempts made is returned as well.
+func (c *Client) withRetry(ctx context.Context, method, path string, do func() error, next func() (bool, error)) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := do()
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= c.retry.MaxAttempts || !c.retry.shouldRetry(err) || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		delay := c.retry.backoff(attempt, err)
+		c.log().DebugContext(ctx, "retrying request",
+			"method", method, "path", path, "attempt", attempt+1, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+
+		if next != nil {
+			retry, nextErr := next()
+			if nextErr != nil {
+				return attempt, nextErr
+			}
+			if !retry {
+				return attempt, err
+			}
+		}
+	}
+}
+
+// sendOnce performs a single attempt of req
+func (c *Client) sendOnce(req *http.Request) (*http.Response, error) {
+	release, err := c.limit(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.authenticate(req); err != nil {
+		release()
+		return nil, err
+	}
+
+	c.log().DebugContext(req.Context(), "http request",
+		"method", req.Method, "path", c.endpointPath(req.URL.Path), "size", req.ContentLength)
+
+	start := time.Now()
+	resp, err := c.roundTrip(req)
+	latency := time.Since(start)
+	c.logResponse(req, resp, err, latency)
+	if c.metrics != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		c.metrics.RequestCompleted(req.Method, endpointLabel(c.endpointPath(req.URL.Path)), status, latency)
+	}
+	if err != nil {
+		release()
+		return nil, transportError(req, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer release()
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(req, resp, body)
+	}
+
+	// Hold the limiter slot until the caller has read the body
+	resp.Body = releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// nodeErrorsFromResponse converts the node_errors of a successful /prompt
+// response, reported when some outputs failed validation
+func nodeErrorsFromResponse(nodeErrors map[string]interface{}) *PromptValidationError {
+	perr := &PromptValidationError{
+		Type:    "prompt_outputs_failed_validation",
+		Message: "Prompt outputs failed validation",
+	}
+
+	data, err := json.Marshal(nodeErrors)
+	if err != nil {
+		return perr
+	}
+	var parsed map[string]comfyNodeErrors
+	if err := json.Unmarshal(data, &parsed); err == nil {
+		perr.NodeErrors = newNodeValidationErrors(parsed)
+	}
+	return perr
+}
@@ -0,0 +1,165 @@
+package comfyui
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// CompletionStrategy decides how Run and WaitForCompletion learn that a
+// prompt has finished
+type CompletionStrategy interface {
+	// Watch starts observing promptID. Run calls it before the prompt is
+	// queued, so implementations must tolerate a prompt that is not known yet.
+	Watch(ctx context.Context, c *Client, promptID string) (CompletionWatcher, error)
+}
+
+// CompletionWatcher waits for a single prompt to finish
+type CompletionWatcher interface {
+	// Wait blocks until the prompt has finished executing. A prompt that
+	// failed may be reported with a *NodeError or an error wrapping
+	// ErrInterrupted; otherwise Client checks the history status.
+	Wait(ctx context.Context) error
+	// Close releases the resources held by the watcher
+	Close()
+}
+
+// WebSocketCompletion waits for completion events on the client's shared
+// WebSocket connection. It is the default strategy.
+type WebSocketCompletion struct{}
+
+// Watch subscribes to the prompt's events
+func (WebSocketCompletion) Watch(ctx context.Context, c *Client, promptID string) (CompletionWatcher, error) {
+	sub, err := c.Subscribe(ctx, promptID)
+	if err != nil {
+		return nil, err
+	}
+	return &webSocketWatcher{client: c, sub: sub}, nil
+}
+
+type webSocketWatcher struct {
+	client *Client
+	sub    *Subscription
+}
+
+// Wait waits for the final executing event. Errors and interrupts are
+// remembered and returned once the prompt has finished, so that its history
+// entry is available. After a reconnect the history is checked, since the
+// completion may have been missed while disconnected.
+func (w *webSocketWatcher) Wait(ctx context.Context) error {
+	var failure error
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-w.sub.Events():
+			if !ok {
+				return fmt.Errorf("websocket closed unexpectedly")
+			}
+
+			switch ev := ev.(type) {
+			case ExecutingEvent:
+				if ev.Done() {
+					return failure // Execution completed
+				}
+			case ExecutionErrorEvent:
+				failure = newNodeError(&ev.ErrorData)
+			case ExecutionInterruptedEvent:
+				failure = newInterruptedError(ev.PromptID, ev.NodeID, ev.NodeType)
+			case ReconnectedEvent:
+				if done, err := w.client.hasHistory(ctx, w.sub.PromptID()); err != nil {
+					return err
+				} else if done {
+					return failure
+				}
+			}
+		}
+	}
+}
+
+func (w *webSocketWatcher) Close() {
+	w.sub.Close()
+}
+
+// PollingCompletion detects completion by polling the queue and history,
+// for deployments where WebSocket upgrades are not available
+type PollingCompletion struct {
+	Interval time.Duration // time between polls, defaults to 1s
+	Jitter   time.Duration // random extra delay added to each interval
+}
+
+// Watch returns a watcher that polls for promptID
+func (p PollingCompletion) Watch(ctx context.Context, c *Client, promptID string) (CompletionWatcher, error) {
+	return &pollingWatcher{client: c, promptID: promptID, strategy: p}, nil
+}
+
+type pollingWatcher struct {
+	client   *Client
+	promptID string
+	strategy PollingCompletion
+}
+
+// Wait polls until the prompt has left the queue and has a history entry
+func (w *pollingWatcher) Wait(ctx context.Context) error {
+	for {
+		queue, err := w.client.GetQueue(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !queue.contains(w.promptID) {
+			done, err := w.client.hasHistory(ctx, w.promptID)
+			if err != nil || done {
+				return err
+			}
+		}
+
+		timer := time.NewTimer(w.strategy.delay())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *pollingWatcher) Close() {}
+
+// delay returns the time to wait before the next poll
+func (p PollingCompletion) delay() time.Duration {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if p.Jitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	return interval
+}
+
+// contains reports whether promptID is running or pending
+func (q *QueueStatus) contains(promptID string) bool {
+	for _, item := range q.QueueRunning {
+		if item.PromptID == promptID {
+			return true
+		}
+	}
+	for _, item := range q.QueuePending {
+		if item.PromptID == promptID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHistory reports whether promptID has a history entry, i.e. has finished
+func (c *Client) hasHistory(ctx context.Context, promptID string) (bool, error) {
+	history, err := c.GetHistory(ctx, promptID)
+	if err != nil {
+		return false, err
+	}
+	_, ok := history[promptID]
+	return ok, nil
+}
@@ -0,0 +1,227 @@
+package comfyui
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPollingCompletion(t *testing.T) {
+	server := newFakeComfyUI(t)
+
+	var finished int32
+	server.onPrompt = func(req QueuePromptRequest) {
+		server.setRunning(req.PromptID)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			server.setRunning()
+			server.setHistory(req.PromptID, successHistory(req.PromptID))
+			atomic.StoreInt32(&finished, 1)
+		}()
+	}
+
+	client := NewClient(server.URL)
+	client.SetCompletionStrategy(PollingCompletion{
+		Interval: 10 * time.Millisecond,
+		Jitter:   5 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := client.Run(ctx, Workflow{"9": Node{ClassType: "SaveImage"}}, RunOptions{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("Run returned before the prompt finished")
+	}
+	if len(result.Images) != 1 || !result.Status.Completed {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if n := server.connectionCount(); n != 0 {
+		t.Errorf("Expected no WebSocket connections, got %d", n)
+	}
+}
+
+func TestPollingCompletionContextCancel(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.setRunning("stuck")
+
+	client := NewClient(server.URL)
+	client.SetCompletionStrategy(PollingCompletion{Interval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.WaitForCompletion(ctx, "stuck"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestPollingCompletionDelay(t *testing.T) {
+	if d := (PollingCompletion{}).delay(); d != time.Second {
+		t.Errorf("Expected default interval of 1s, got %v", d)
+	}
+
+	p := PollingCompletion{Interval: 100 * time.Millisecond, Jitter: 50 * time.Millisecond}
+	for i := 0; i < 20; i++ {
+		if d := p.delay(); d < 100*time.Millisecond || d >= 150*time.Millisecond {
+			t.Fatalf("Delay %v outside [100ms, 150ms)", d)
+		}
+	}
+}
+
+// errorHistory returns a history entry for a prompt that failed in node 4
+func errorHistory(promptID string) map[string]interface{} {
+	return map[string]interface{}{
+		"prompt":  []interface{}{1, promptID, map[string]interface{}{}, map[string]interface{}{}, []interface{}{"9"}},
+		"outputs": map[string]interface{}{},
+		"status": map[string]interface{}{
+			"status_str": "error",
+			"completed":  false,
+			"messages": []interface{}{
+				[]interface{}{"execution_start", map[string]interface{}{"prompt_id": promptID}},
+				[]interface{}{"execution_error", map[string]interface{}{
+					"prompt_id":         promptID,
+					"node_id":           "4",
+					"node_type":         "KSampler",
+					"exception_type":    "RuntimeError",
+					"exception_message": "CUDA out of memory",
+					"traceback":         []interface{}{"Traceback (most recent call last):"},
+				}},
+			},
+		},
+	}
+}
+
+func TestRunReturnsNodeError(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.onPrompt = func(req QueuePromptRequest) {
+		server.send("execution_error", map[string]interface{}{
+			"prompt_id":         req.PromptID,
+			"node_id":           "4",
+			"node_type":         "KSampler",
+			"exception_type":    "RuntimeError",
+			"exception_message": "CUDA out of memory",
+			"traceback":         []string{"Traceback (most recent call last):"},
+		})
+		server.setHistory(req.PromptID, errorHistory(req.PromptID))
+		server.send("executing", map[string]interface{}{"prompt_id": req.PromptID, "node": nil})
+	}
+
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := client.Run(ctx, Workflow{"4": Node{ClassType: "KSampler"}}, RunOptions{})
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) {
+		t.Fatalf("Expected *NodeError, got %v", err)
+	}
+	if nodeErr.NodeID != "4" || nodeErr.NodeType != "KSampler" || nodeErr.ExceptionType != "RuntimeError" || len(nodeErr.Traceback) != 1 {
+		t.Errorf("Unexpected node error: %+v", nodeErr)
+	}
+	if !errors.Is(err, ErrExecutionFailed) {
+		t.Error("Expected error to wrap ErrExecutionFailed")
+	}
+	if result == nil || result.Status.StatusStr != "error" {
+		t.Errorf("Expected result with error status, got %+v", result)
+	}
+}
+
+func TestRunReturnsInterrupted(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.onPrompt = func(req QueuePromptRequest) {
+		server.send("execution_interrupted", map[string]interface{}{
+			"prompt_id": req.PromptID,
+			"node_id":   "4",
+			"node_type": "KSampler",
+			"executed":  []string{"1"},
+		})
+		server.setHistory(req.PromptID, map[string]interface{}{
+			"prompt":  []interface{}{1, req.PromptID, map[string]interface{}{}, map[string]interface{}{}, []interface{}{}},
+			"outputs": map[string]interface{}{},
+			"status":  map[string]interface{}{"status_str": "error", "completed": false},
+		})
+		server.send("executing", map[string]interface{}{"prompt_id": req.PromptID, "node": nil})
+	}
+
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := client.Run(ctx, Workflow{"4": Node{ClassType: "KSampler"}}, RunOptions{})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("Expected ErrInterrupted, got %v", err)
+	}
+	if result == nil || result.Status.StatusStr != "error" {
+		t.Errorf("Expected result with error status, got %+v", result)
+	}
+}
+
+func TestPollingCompletionReturnsNodeError(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.setHistory("failed", errorHistory("failed"))
+
+	client := NewClient(server.URL)
+	client.SetCompletionStrategy(PollingCompletion{Interval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := client.WaitForCompletion(ctx, "failed")
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.Message != "CUDA out of memory" {
+		t.Fatalf("Expected *NodeError, got %v", err)
+	}
+	if result == nil || result.Status.Completed {
+		t.Errorf("Expected result with failed status, got %+v", result)
+	}
+}
+
+func TestHistoryStatusExecutionError(t *testing.T) {
+	if err := (HistoryStatus{StatusStr: "success", Completed: true}).ExecutionError(); err != nil {
+		t.Errorf("Expected nil for successful status, got %v", err)
+	}
+	if err := (HistoryStatus{StatusStr: "error"}).ExecutionError(); !errors.Is(err, ErrExecutionFailed) {
+		t.Errorf("Expected ErrExecutionFailed, got %v", err)
+	}
+
+	status := HistoryStatus{
+		StatusStr: "error",
+		Messages: []interface{}{
+			[]interface{}{"execution_interrupted", map[string]interface{}{"prompt_id": "p1", "node_id": "4", "node_type": "KSampler"}},
+		},
+	}
+	if err := status.ExecutionError(); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("Expected ErrInterrupted, got %v", err)
+	}
+}
+
+func TestRunRejectsServerGeneratedID(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.ignorePromptID = true
+
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Run(ctx, Workflow{"9": Node{ClassType: "SaveImage"}}, RunOptions{PromptID: "fixed-id"})
+	if !errors.Is(err, ErrInvalidResponse) || !strings.Contains(err.Error(), "generated-id") {
+		t.Fatalf("Expected prompt ID mismatch error, got %v", err)
+	}
+	if ctx.Err() != nil || time.Since(start) > 2*time.Second {
+		t.Error("Expected Run to fail without waiting for the context")
+	}
+}
@@ -0,0 +1,77 @@
+package comfyui
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// apiPrefix is the prefix under which newer ComfyUI versions also serve every route
+const apiPrefix = "/api"
+
+// WithAPIRoutes sends requests to the /api prefixed routes, e.g. /api/prompt
+// and /api/ws, which newer ComfyUI versions expose alongside the bare ones
+func WithAPIRoutes() Option {
+	return func(o *clientOptions) {
+		o.apiRoutes = true
+	}
+}
+
+// parseBaseURL parses the client's base URL. Its path is kept as a prefix
+// for every endpoint and its query parameters are sent with every request.
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("failed to parse base URL: %q is not an absolute URL", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	u.Fragment = ""
+	return u, nil
+}
+
+// endpoint returns the URL for path below the base URL. The base URL's query
+// parameters are merged with query, which takes precedence.
+func (c *Client) endpoint(path string, query url.Values) (*url.URL, error) {
+	if c.baseErr != nil {
+		return nil, c.baseErr
+	}
+
+	u := *c.base
+	u.Path = c.base.Path + c.pathPrefix + path
+
+	params := c.base.Query()
+	for key, values := range query {
+		params[key] = values
+	}
+	u.RawQuery = params.Encode()
+	return &u, nil
+}
+
+// wsEndpoint returns the URL of the WebSocket endpoint for clientID
+func (c *Client) wsEndpoint(clientID string) (string, error) {
+	u, err := c.endpoint("/ws", url.Values{"clientId": {clientID}})
+	if err != nil {
+		return "", err
+	}
+
+	// Convert http/https to ws/wss
+	switch u.Scheme {
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	return u.String(), nil
+}
+
+// endpointPath strips the base URL's path prefix from a request path
+func (c *Client) endpointPath(path string) string {
+	if c.base != nil {
+		path = strings.TrimPrefix(path, c.base.Path)
+	}
+	return strings.TrimPrefix(path, c.pathPrefix)
+}
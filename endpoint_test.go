@@ -0,0 +1,101 @@
+package comfyui
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		opts    []Option
+		http    string
+		ws      string
+	}{
+		{"http://127.0.0.1:8188", nil, "http://127.0.0.1:8188/history/abc", "ws://127.0.0.1:8188/ws?clientId=cid"},
+		{"https://gateway/comfy/", nil, "https://gateway/comfy/history/abc", "wss://gateway/comfy/ws?clientId=cid"},
+		{"https://gateway/comfy?token=t", nil, "https://gateway/comfy/history/abc?token=t", "wss://gateway/comfy/ws?clientId=cid&token=t"},
+		{"http://127.0.0.1:8188", []Option{WithAPIRoutes()}, "http://127.0.0.1:8188/api/history/abc", "ws://127.0.0.1:8188/api/ws?clientId=cid"},
+	}
+
+	for _, tt := range tests {
+		client := NewClient(tt.baseURL, append(tt.opts, WithClientID("cid"))...)
+		u, err := client.endpoint("/history/abc", nil)
+		if err != nil {
+			t.Fatalf("%s: endpoint failed: %v", tt.baseURL, err)
+		}
+		if u.String() != tt.http {
+			t.Errorf("%s: endpoint = %s, want %s", tt.baseURL, u, tt.http)
+		}
+		ws, err := client.wsEndpoint(client.GetClientID())
+		if err != nil {
+			t.Fatalf("%s: wsEndpoint failed: %v", tt.baseURL, err)
+		}
+		if ws != tt.ws {
+			t.Errorf("%s: wsEndpoint = %s, want %s", tt.baseURL, ws, tt.ws)
+		}
+	}
+
+	// Request parameters override base parameters
+	client := NewClient("http://host/?type=input&token=t")
+	u, _ := client.endpoint("/view", map[string][]string{"type": {"output"}})
+	if q := u.Query(); q.Get("type") != "output" || q.Get("token") != "t" {
+		t.Errorf("Unexpected query: %s", u.RawQuery)
+	}
+}
+
+func TestInvalidBaseURL(t *testing.T) {
+	client := NewClient("127.0.0.1:8188")
+	if _, err := client.GetQueue(context.Background()); err == nil || !strings.Contains(err.Error(), "base URL") {
+		t.Errorf("Expected base URL error, got %v", err)
+	}
+	if _, err := client.ConnectWebSocket(context.Background()); err == nil {
+		t.Error("Expected ConnectWebSocket to fail")
+	}
+}
+
+func TestPathPrefixHTTPAndWebSocket(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/comfy/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path+"?"+r.URL.RawQuery)
+		mu.Unlock()
+
+		if r.URL.Path == "/comfy/ws" {
+			if conn, err := upgrader.Upgrade(w, r, nil); err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL+"/comfy/?key=k", WithClientID("cid"))
+	if _, err := client.GetHistory(context.Background(), ""); err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	ws, err := client.ConnectWebSocket(context.Background())
+	if err != nil {
+		t.Fatalf("ConnectWebSocket failed: %v", err)
+	}
+	ws.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"/comfy/history?key=k", "/comfy/ws?clientId=cid&key=k"}
+	if strings.Join(paths, " ") != strings.Join(expected, " ") {
+		t.Errorf("Requested %v, want %v", paths, expected)
+	}
+}
@@ -1,49 +1,309 @@
 package comfyui
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
 
 // Error types
 var (
 	ErrNodeNotFound     = fmt.Errorf("node not found")
 	ErrInvalidWorkflow  = fmt.Errorf("invalid workflow")
 	ErrExecutionFailed  = fmt.Errorf("execution failed")
+	ErrInterrupted      = fmt.Errorf("execution interrupted")
 	ErrConnectionFailed = fmt.Errorf("connection failed")
 	ErrTimeout          = fmt.Errorf("timeout")
 	ErrInvalidResponse  = fmt.Errorf("invalid response")
 )
 
-// APIError represents an API error
+// APIError represents an API error, returned for every non-2xx response.
+// It wraps ErrTimeout (408, 504), ErrConnectionFailed (502, 503),
+// *PromptValidationError for rejected prompts, or ErrInvalidResponse otherwise.
 type APIError struct {
 	StatusCode int
 	Message    string
-	Details    interface{}
+	Details    interface{} // response body, decoded from JSON when possible
+	Method     string
+	Path       string
+	Body       []byte
+	RetryAfter time.Duration // parsed from the Retry-After header, if any
+	Err        error
 }
 
 func (e *APIError) Error() string {
+	prefix := fmt.Sprintf("API error (status %d)", e.StatusCode)
+	if e.Method != "" {
+		prefix = fmt.Sprintf("API error (status %d) %s %s", e.StatusCode, e.Method, e.Path)
+	}
 	if e.Details != nil {
-		return fmt.Sprintf("API error (status %d): %s - %v", e.StatusCode, e.Message, e.Details)
+		return fmt.Sprintf("%s: %s - %v", prefix, e.Message, e.Details)
+	}
+	return fmt.Sprintf("%s: %s", prefix, e.Message)
+}
+
+// Unwrap returns the wrapped sentinel or validation error
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError builds the error for a non-2xx response to req
+func newAPIError(req *http.Request, resp *http.Response, body []byte) *APIError {
+	statusCode := resp.StatusCode
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Message:    http.StatusText(statusCode),
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Body:       body,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	var details interface{}
+	if err := json.Unmarshal(body, &details); err == nil {
+		apiErr.Details = details
+		if m, ok := details.(map[string]interface{}); ok {
+			if msg, ok := m["message"].(string); ok && msg != "" {
+				apiErr.Message = msg
+			} else if msg, ok := m["error"].(string); ok && msg != "" {
+				apiErr.Message = msg
+			}
+		}
+	} else if len(body) > 0 {
+		apiErr.Details = strings.TrimSpace(string(body))
+	}
+
+	switch {
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		apiErr.Err = ErrTimeout
+	case statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable:
+		apiErr.Err = ErrConnectionFailed
+	default:
+		if perr := parsePromptValidationError(body); perr != nil {
+			apiErr.Message = perr.Error()
+			apiErr.Details = nil
+			apiErr.Err = perr
+		} else {
+			apiErr.Err = ErrInvalidResponse
+		}
+	}
+
+	return apiErr
+}
+
+// transportError wraps a failed round trip with ErrTimeout or
+// ErrConnectionFailed. Cancellation by the caller is only wrapped as-is.
+func transportError(req *http.Request, err error) error {
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err)
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %s %s: %w", ErrTimeout, req.Method, req.URL.Path, err)
 	}
-	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+	return fmt.Errorf("%w: %s %s: %w", ErrConnectionFailed, req.Method, req.URL.Path, err)
 }
 
 // NodeError represents a node execution error
 type NodeError struct {
-	NodeID    string
-	NodeType  string
-	Message   string
-	Traceback []string
+	PromptID      string
+	NodeID        string
+	NodeType      string
+	ExceptionType string
+	Message       string
+	Traceback     []string
 }
 
 func (e *NodeError) Error() string {
 	return fmt.Sprintf("node error in %s (%s): %s", e.NodeID, e.NodeType, e.Message)
 }
 
+// Unwrap allows errors.Is(err, ErrExecutionFailed)
+func (e *NodeError) Unwrap() error {
+	return ErrExecutionFailed
+}
+
+// newNodeError converts the data of an execution_error message
+func newNodeError(data *ErrorData) *NodeError {
+	return &NodeError{
+		PromptID:      data.PromptID,
+		NodeID:        data.NodeID,
+		NodeType:      data.NodeType,
+		ExceptionType: data.ExceptionType,
+		Message:       data.ExceptionMessage,
+		Traceback:     data.Traceback,
+	}
+}
+
+// newInterruptedError reports an interrupted prompt, wrapping ErrInterrupted
+func newInterruptedError(promptID, nodeID, nodeType string) error {
+	if nodeID == "" {
+		return fmt.Errorf("%w: prompt %s", ErrInterrupted, promptID)
+	}
+	return fmt.Errorf("%w: prompt %s at node %s (%s)", ErrInterrupted, promptID, nodeID, nodeType)
+}
+
 // ValidationError represents a workflow validation error
 type ValidationError struct {
-	Field   string
-	Message string
+	NodeID    string // node the error belongs to, if any
+	Field     string // offending input name or workflow field
+	Type      string // ComfyUI error type, e.g. "value_not_in_list"
+	Message   string
+	Details   string
+	ExtraInfo map[string]interface{}
 }
 
 func (e *ValidationError) Error() string {
-	return fmt.Sprintf("validation error in %s: %s", e.Field, e.Message)
+	msg := e.Message
+	if e.Details != "" {
+		msg = fmt.Sprintf("%s: %s", e.Message, e.Details)
+	}
+	switch {
+	case e.NodeID != "" && e.Field != "":
+		return fmt.Sprintf("validation error in node %s input %s: %s", e.NodeID, e.Field, msg)
+	case e.NodeID != "":
+		return fmt.Sprintf("validation error in node %s: %s", e.NodeID, msg)
+	}
+	return fmt.Sprintf("validation error in %s: %s", e.Field, msg)
+}
+
+// ValidationErrors lists the problems found by Workflow.ValidateAgainst
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "workflow validation failed with %d error(s)", len(e))
+	for i := range e {
+		b.WriteString("; ")
+		b.WriteString(e[i].Error())
+	}
+	return b.String()
+}
+
+// Unwrap allows errors.Is(err, ErrInvalidWorkflow)
+func (e ValidationErrors) Unwrap() error {
+	return ErrInvalidWorkflow
+}
+
+// NodeValidationErrors lists the validation errors ComfyUI reported for one node
+type NodeValidationErrors struct {
+	ClassType        string
+	Errors           []ValidationError
+	DependentOutputs []string
+}
+
+// PromptValidationError is returned when ComfyUI rejects a prompt.
+// NodeErrors is keyed by node ID.
+type PromptValidationError struct {
+	Type       string // e.g. "prompt_outputs_failed_validation"
+	Message    string
+	Details    string
+	ExtraInfo  map[string]interface{}
+	NodeErrors map[string]NodeValidationErrors
+}
+
+func (e *PromptValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("prompt validation failed")
+	if e.Message != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	}
+
+	nodeIDs := make([]string, 0, len(e.NodeErrors))
+	for id := range e.NodeErrors {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	for _, id := range nodeIDs {
+		for _, verr := range e.NodeErrors[id].Errors {
+			b.WriteString("; ")
+			b.WriteString(verr.Error())
+		}
+	}
+	return b.String()
+}
+
+// Unwrap allows errors.Is(err, ErrInvalidWorkflow)
+func (e *PromptValidationError) Unwrap() error {
+	return ErrInvalidWorkflow
+}
+
+// comfyError is the error object ComfyUI uses in /prompt responses
+type comfyError struct {
+	Type      string                 `json:"type"`
+	Message   string                 `json:"message"`
+	Details   string                 `json:"details"`
+	ExtraInfo map[string]interface{} `json:"extra_info"`
+}
+
+// comfyNodeErrors is the per-node entry of node_errors
+type comfyNodeErrors struct {
+	Errors           []comfyError `json:"errors"`
+	DependentOutputs []string     `json:"dependent_outputs"`
+	ClassType        string       `json:"class_type"`
+}
+
+// parsePromptValidationError decodes a /prompt error body of the form
+// {"error": {...}, "node_errors": {...}}. It returns nil if body has another shape.
+func parsePromptValidationError(body []byte) *PromptValidationError {
+	var raw struct {
+		Error      json.RawMessage `json:"error"`
+		NodeErrors json.RawMessage `json:"node_errors"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil || raw.NodeErrors == nil {
+		return nil
+	}
+
+	perr := &PromptValidationError{}
+
+	// Older ComfyUI versions send a plain string
+	var top comfyError
+	if err := json.Unmarshal(raw.Error, &top); err == nil {
+		perr.Type = top.Type
+		perr.Message = top.Message
+		perr.Details = top.Details
+		perr.ExtraInfo = top.ExtraInfo
+	} else {
+		json.Unmarshal(raw.Error, &perr.Message)
+	}
+
+	// node_errors is an empty list when no node is at fault
+	var nodeErrors map[string]comfyNodeErrors
+	if err := json.Unmarshal(raw.NodeErrors, &nodeErrors); err == nil {
+		perr.NodeErrors = newNodeValidationErrors(nodeErrors)
+	}
+
+	return perr
+}
+
+// newNodeValidationErrors converts the node_errors object of a /prompt response
+func newNodeValidationErrors(nodeErrors map[string]comfyNodeErrors) map[string]NodeValidationErrors {
+	result := make(map[string]NodeValidationErrors, len(nodeErrors))
+	for nodeID, ne := range nodeErrors {
+		entry := NodeValidationErrors{
+			ClassType:        ne.ClassType,
+			DependentOutputs: ne.DependentOutputs,
+		}
+		for _, e := range ne.Errors {
+			verr := ValidationError{
+				NodeID:    nodeID,
+				Type:      e.Type,
+				Message:   e.Message,
+				Details:   e.Details,
+				ExtraInfo: e.ExtraInfo,
+			}
+			verr.Field, _ = e.ExtraInfo["input_name"].(string)
+			entry.Errors = append(entry.Errors, verr)
+		}
+		result[nodeID] = entry
+	}
+	return result
 }
@@ -0,0 +1,198 @@
+package comfyui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const promptValidationBody = `{
+	"error": {
+		"type": "prompt_outputs_failed_validation",
+		"message": "Prompt outputs failed validation",
+		"details": "",
+		"extra_info": {}
+	},
+	"node_errors": {
+		"4": {
+			"errors": [{
+				"type": "value_not_in_list",
+				"message": "Value not in list",
+				"details": "ckpt_name: 'missing.safetensors' not in ['sd15.safetensors']",
+				"extra_info": {
+					"input_name": "ckpt_name",
+					"input_config": [["sd15.safetensors"]],
+					"received_value": "missing.safetensors"
+				}
+			}],
+			"dependent_outputs": ["9"],
+			"class_type": "CheckpointLoaderSimple"
+		}
+	}
+}`
+
+func TestQueuePromptValidationError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(promptValidationBody))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, err := client.QueuePrompt(context.Background(), Workflow{"4": Node{ClassType: "CheckpointLoaderSimple"}}, nil)
+
+	var perr *PromptValidationError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected *PromptValidationError, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidWorkflow) {
+		t.Error("Expected error to wrap ErrInvalidWorkflow")
+	}
+	if perr.Type != "prompt_outputs_failed_validation" || perr.Message != "Prompt outputs failed validation" {
+		t.Errorf("Unexpected top-level error: %+v", perr)
+	}
+
+	node, ok := perr.NodeErrors["4"]
+	if !ok {
+		t.Fatalf("Expected errors for node 4, got %+v", perr.NodeErrors)
+	}
+	if node.ClassType != "CheckpointLoaderSimple" || len(node.DependentOutputs) != 1 {
+		t.Errorf("Unexpected node entry: %+v", node)
+	}
+	if len(node.Errors) != 1 {
+		t.Fatalf("Expected 1 validation error, got %d", len(node.Errors))
+	}
+	verr := node.Errors[0]
+	if verr.NodeID != "4" || verr.Field != "ckpt_name" || verr.Type != "value_not_in_list" {
+		t.Errorf("Unexpected validation error: %+v", verr)
+	}
+	if verr.ExtraInfo["received_value"] != "missing.safetensors" {
+		t.Errorf("Expected extra info to be preserved, got %v", verr.ExtraInfo)
+	}
+	if !strings.Contains(err.Error(), "ckpt_name") {
+		t.Errorf("Expected error message to mention the input, got %q", err.Error())
+	}
+}
+
+func TestParsePromptValidationErrorShapes(t *testing.T) {
+	// Invalid prompt without node errors
+	perr := parsePromptValidationError([]byte(`{"error": {"type": "invalid_prompt", "message": "Cannot execute because node Foo does not exist.", "details": "Node ID '#1'", "extra_info": {}}, "node_errors": []}`))
+	if perr == nil || perr.Type != "invalid_prompt" || len(perr.NodeErrors) != 0 {
+		t.Errorf("Unexpected result: %+v", perr)
+	}
+
+	// Older servers report the error as a string
+	perr = parsePromptValidationError([]byte(`{"error": "no prompt", "node_errors": []}`))
+	if perr == nil || perr.Message != "no prompt" {
+		t.Errorf("Unexpected result: %+v", perr)
+	}
+
+	// Unrelated bodies are not validation errors
+	if perr := parsePromptValidationError([]byte(`Internal Server Error`)); perr != nil {
+		t.Errorf("Expected nil, got %+v", perr)
+	}
+	if perr := parsePromptValidationError([]byte(`{"error": "boom"}`)); perr != nil {
+		t.Errorf("Expected nil, got %+v", perr)
+	}
+}
+
+func TestAPIErrorFromEveryCall(t *testing.T) {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error": "not here"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"doRequest": func() error {
+			_, err := client.GetHistory(ctx, "missing")
+			return err
+		},
+		"GetImage": func() error {
+			_, err := client.GetImage(ctx, "a.png", "", "output")
+			return err
+		},
+		"UploadImageBytes": func() error {
+			_, err := client.UploadImageBytes(ctx, []byte("data"), "a.png", UploadOptions{})
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			status = http.StatusNotFound
+			err := call()
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected *APIError, got %v", err)
+			}
+			if apiErr.StatusCode != http.StatusNotFound || apiErr.Method == "" || apiErr.Path == "" {
+				t.Errorf("Unexpected API error: %+v", apiErr)
+			}
+			if apiErr.Message != "not here" {
+				t.Errorf("Expected message from body, got %q", apiErr.Message)
+			}
+			if !errors.Is(err, ErrInvalidResponse) {
+				t.Error("Expected error to wrap ErrInvalidResponse")
+			}
+
+			status = http.StatusServiceUnavailable
+			if err := call(); !errors.Is(err, ErrConnectionFailed) {
+				t.Errorf("Expected 503 to wrap ErrConnectionFailed, got %v", err)
+			}
+
+			status = http.StatusGatewayTimeout
+			if err := call(); !errors.Is(err, ErrTimeout) {
+				t.Errorf("Expected 504 to wrap ErrTimeout, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`not json`))
+	}))
+	client := NewClient(server.URL)
+
+	if _, err := client.GetQueue(context.Background()); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("Expected decode failure to wrap ErrInvalidResponse, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetQueue(ctx); !errors.Is(err, context.Canceled) || errors.Is(err, ErrConnectionFailed) {
+		t.Errorf("Expected cancellation to be reported as-is, got %v", err)
+	}
+
+	server.Close()
+	if _, err := client.GetQueue(context.Background()); !errors.Is(err, ErrConnectionFailed) {
+		t.Errorf("Expected connection failure to wrap ErrConnectionFailed, got %v", err)
+	}
+}
+
+func TestQueuePromptValidationAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(promptValidationBody))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL).QueuePrompt(context.Background(), Workflow{}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Path != "/prompt" {
+		t.Fatalf("Expected *APIError for POST /prompt, got %v", err)
+	}
+	var perr *PromptValidationError
+	if !errors.As(err, &perr) {
+		t.Error("Expected *APIError to wrap *PromptValidationError")
+	}
+}
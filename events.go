@@ -0,0 +1,209 @@
+package comfyui
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Event is a typed WebSocket event decoded from a WebSocketMessage.
+// The set of implementations is closed; consumers use a type switch:
+//
+//	switch ev := msg.Event.(type) {
+//	case comfyui.ProgressEvent:
+//		fmt.Printf("%d/%d\n", ev.Value, ev.Max)
+//	case comfyui.ExecutingEvent:
+//		...
+//	}
+type Event interface {
+	EventType() MessageType
+	isEvent()
+}
+
+// StatusEvent reports the server queue status
+type StatusEvent struct {
+	QueueRemaining int
+	SID            string
+}
+
+// ExecutionStartEvent is sent when a prompt starts executing
+type ExecutionStartEvent struct {
+	PromptID  string `json:"prompt_id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ExecutingEvent is sent when a node starts executing.
+// A nil Node means the prompt has finished.
+type ExecutingEvent struct {
+	PromptID    string  `json:"prompt_id"`
+	Node        *string `json:"node"`
+	DisplayNode string  `json:"display_node"`
+}
+
+// Done reports whether the event marks the end of the prompt
+func (e ExecutingEvent) Done() bool {
+	return e.Node == nil || *e.Node == ""
+}
+
+// ProgressEvent reports sampler progress for a node
+type ProgressEvent struct {
+	PromptID string `json:"prompt_id"`
+	Node     string `json:"node"`
+	Value    int    `json:"value"`
+	Max      int    `json:"max"`
+}
+
+// ExecutedEvent is sent when a node produced output
+type ExecutedEvent struct {
+	PromptID    string     `json:"prompt_id"`
+	Node        string     `json:"node"`
+	DisplayNode string     `json:"display_node"`
+	Output      NodeOutput `json:"output"`
+}
+
+// ExecutionCachedEvent lists the nodes whose cached outputs were reused
+type ExecutionCachedEvent struct {
+	PromptID  string   `json:"prompt_id"`
+	Nodes     []string `json:"nodes"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// ExecutionErrorEvent is sent when a node raised an exception
+type ExecutionErrorEvent struct {
+	ErrorData
+	Timestamp int64 `json:"timestamp"`
+}
+
+// ExecutionInterruptedEvent is sent when a prompt was interrupted
+type ExecutionInterruptedEvent struct {
+	PromptID  string   `json:"prompt_id"`
+	NodeID    string   `json:"node_id"`
+	NodeType  string   `json:"node_type"`
+	Executed  []string `json:"executed"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// ExecutionSuccessEvent is sent when a prompt finished without errors
+type ExecutionSuccessEvent struct {
+	PromptID  string `json:"prompt_id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ReconnectedEvent is synthesized by a reconnecting WebSocketClient
+type ReconnectedEvent struct {
+	Attempt int    `json:"attempt"`
+	SID     string `json:"sid"`
+}
+
+// UnknownEvent carries messages of a type the SDK does not model
+type UnknownEvent struct {
+	Type string
+	Data map[string]interface{}
+}
+
+func (StatusEvent) EventType() MessageType               { return MessageTypeStatus }
+func (ExecutionStartEvent) EventType() MessageType       { return MessageTypeExecutionStart }
+func (ExecutingEvent) EventType() MessageType            { return MessageTypeExecuting }
+func (ProgressEvent) EventType() MessageType             { return MessageTypeProgress }
+func (ExecutedEvent) EventType() MessageType             { return MessageTypeExecuted }
+func (ExecutionCachedEvent) EventType() MessageType      { return MessageTypeCached }
+func (ExecutionErrorEvent) EventType() MessageType       { return MessageTypeError }
+func (ExecutionInterruptedEvent) EventType() MessageType { return MessageTypeInterrupted }
+func (ExecutionSuccessEvent) EventType() MessageType     { return MessageTypeExecutionSuccess }
+func (ReconnectedEvent) EventType() MessageType          { return MessageTypeReconnected }
+func (e UnknownEvent) EventType() MessageType            { return MessageType(e.Type) }
+
+func (StatusEvent) isEvent()               {}
+func (ExecutionStartEvent) isEvent()       {}
+func (ExecutingEvent) isEvent()            {}
+func (ProgressEvent) isEvent()             {}
+func (ExecutedEvent) isEvent()             {}
+func (ExecutionCachedEvent) isEvent()      {}
+func (ExecutionErrorEvent) isEvent()       {}
+func (ExecutionInterruptedEvent) isEvent() {}
+func (ExecutionSuccessEvent) isEvent()     {}
+func (ReconnectedEvent) isEvent()          {}
+func (UnknownEvent) isEvent()              {}
+
+// ParseEvent returns the typed event for the message. Messages received from
+// a WebSocketClient are already decoded; others are decoded from Data.
+func (msg *WebSocketMessage) ParseEvent() (Event, error) {
+	if msg.Event != nil {
+		return msg.Event, nil
+	}
+
+	raw, err := json.Marshal(msg.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message data: %w", err)
+	}
+	return decodeEvent(msg.Type, raw, msg.Data)
+}
+
+// decodeEvent decodes the raw data of a message of the given type.
+// data is the already-decoded map, used for UnknownEvent.
+func decodeEvent(msgType string, raw json.RawMessage, data map[string]interface{}) (Event, error) {
+	var (
+		event Event
+		err   error
+	)
+
+	switch MessageType(msgType) {
+	case MessageTypeStatus:
+		var status StatusData
+		err = unmarshalEventData(raw, &status)
+		event = StatusEvent{
+			QueueRemaining: status.Status.ExecInfo.QueueRemaining,
+			SID:            status.SID,
+		}
+	case MessageTypeExecutionStart:
+		var e ExecutionStartEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	case MessageTypeExecuting:
+		var e ExecutingEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	case MessageTypeProgress:
+		var e ProgressEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	case MessageTypeExecuted:
+		var e ExecutedEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	case MessageTypeCached:
+		var e ExecutionCachedEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	case MessageTypeError:
+		var e ExecutionErrorEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	case MessageTypeInterrupted:
+		var e ExecutionInterruptedEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	case MessageTypeExecutionSuccess:
+		var e ExecutionSuccessEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	case MessageTypeReconnected:
+		var e ReconnectedEvent
+		err = unmarshalEventData(raw, &e)
+		event = e
+	default:
+		return UnknownEvent{Type: msgType, Data: data}, nil
+	}
+
+	if err != nil {
+		return UnknownEvent{Type: msgType, Data: data}, fmt.Errorf("failed to decode %s event: %w", msgType, err)
+	}
+	return event, nil
+}
+
+// unmarshalEventData decodes raw into v, treating a missing payload as empty
+func unmarshalEventData(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
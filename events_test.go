@@ -0,0 +1,149 @@
+package comfyui
+
+import (
+	"testing"
+)
+
+func TestDecodeMessageEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		check func(t *testing.T, ev Event)
+	}{
+		{
+			name:  "status",
+			frame: `{"type":"status","data":{"status":{"exec_info":{"queue_remaining":2}},"sid":"abc"}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(StatusEvent)
+				if !ok || e.QueueRemaining != 2 || e.SID != "abc" {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+		{
+			name:  "executing",
+			frame: `{"type":"executing","data":{"node":"3","prompt_id":"p1"}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(ExecutingEvent)
+				if !ok || e.PromptID != "p1" || e.Node == nil || *e.Node != "3" || e.Done() {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+		{
+			name:  "executing done",
+			frame: `{"type":"executing","data":{"node":null,"prompt_id":"p1"}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(ExecutingEvent)
+				if !ok || !e.Done() {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+		{
+			name:  "progress",
+			frame: `{"type":"progress","data":{"value":5,"max":20,"prompt_id":"p1","node":"3"}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(ProgressEvent)
+				if !ok || e.Value != 5 || e.Max != 20 || e.Node != "3" {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+		{
+			name:  "executed",
+			frame: `{"type":"executed","data":{"node":"9","prompt_id":"p1","output":{"images":[{"filename":"a.png","subfolder":"","type":"output"}]}}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(ExecutedEvent)
+				if !ok || e.Node != "9" || len(e.Output.Images) != 1 || e.Output.Images[0].Filename != "a.png" {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+		{
+			name:  "cached",
+			frame: `{"type":"execution_cached","data":{"nodes":["1","2"],"prompt_id":"p1","timestamp":1}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(ExecutionCachedEvent)
+				if !ok || len(e.Nodes) != 2 {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+		{
+			name:  "error",
+			frame: `{"type":"execution_error","data":{"prompt_id":"p1","node_id":"4","node_type":"KSampler","exception_type":"RuntimeError","exception_message":"boom","traceback":["line 1"]}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(ExecutionErrorEvent)
+				if !ok || e.NodeType != "KSampler" || e.ExceptionMessage != "boom" || len(e.Traceback) != 1 {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+		{
+			name:  "interrupted",
+			frame: `{"type":"execution_interrupted","data":{"prompt_id":"p1","node_id":"4","node_type":"KSampler","executed":["1"]}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(ExecutionInterruptedEvent)
+				if !ok || e.NodeID != "4" || len(e.Executed) != 1 {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+		{
+			name:  "unknown",
+			frame: `{"type":"crystools.monitor","data":{"cpu_utilization":12}}`,
+			check: func(t *testing.T, ev Event) {
+				e, ok := ev.(UnknownEvent)
+				if !ok || e.Type != "crystools.monitor" || e.Data["cpu_utilization"] != float64(12) {
+					t.Errorf("Unexpected event: %#v", ev)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := decodeMessage([]byte(tt.frame))
+			if err != nil {
+				t.Fatalf("Failed to decode message: %v", err)
+			}
+			if msg.Event.EventType() != MessageType(msg.Type) {
+				t.Errorf("Expected event type %s, got %s", msg.Type, msg.Event.EventType())
+			}
+			tt.check(t, msg.Event)
+		})
+	}
+}
+
+func TestDecodeMessageMalformedEvent(t *testing.T) {
+	msg, err := decodeMessage([]byte(`{"type":"progress","data":{"value":"five"}}`))
+	if err == nil {
+		t.Fatal("Expected error for malformed progress data")
+	}
+	if msg == nil {
+		t.Fatal("Expected message to be returned alongside the error")
+	}
+	if _, ok := msg.Event.(UnknownEvent); !ok {
+		t.Errorf("Expected UnknownEvent fallback, got %#v", msg.Event)
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	msg := WebSocketMessage{
+		Type: string(MessageTypeProgress),
+		Data: map[string]interface{}{
+			"value": float64(3),
+			"max":   float64(10),
+		},
+	}
+
+	ev, err := msg.ParseEvent()
+	if err != nil {
+		t.Fatalf("Failed to parse event: %v", err)
+	}
+	progress, ok := ev.(ProgressEvent)
+	if !ok || progress.Value != 3 || progress.Max != 10 {
+		t.Errorf("Unexpected event: %#v", ev)
+	}
+}
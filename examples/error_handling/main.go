@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -65,7 +66,15 @@ func main() {
 	}
 	
 	result, err := client.QueuePrompt(ctx, workflowWithMissingModel, nil)
-	if err != nil {
+	var validationErr *comfyui.PromptValidationError
+	if errors.As(err, &validationErr) {
+		fmt.Printf("   ✓ Correctly caught validation error: %s\n", validationErr.Message)
+		for nodeID, nodeErrs := range validationErr.NodeErrors {
+			for _, e := range nodeErrs.Errors {
+				fmt.Printf("     - node %s (%s) input %q: %s\n", nodeID, nodeErrs.ClassType, e.Field, e.Details)
+			}
+		}
+	} else if err != nil {
 		fmt.Printf("   ✓ Correctly caught missing model error: %v\n", err)
 	} else if len(result.NodeErrors) > 0 {
 		fmt.Printf("   ✓ Node errors detected: %v\n", result.NodeErrors)
@@ -38,19 +38,19 @@ func main() {
 	fmt.Println("Connected! Listening for events...")
 	fmt.Println("Press Ctrl+C to exit")
 
-	// Listen for all messages
+	// Listen for all events
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
-		case msg, ok := <-ws.Messages():
+		case ev, ok := <-ws.Events():
 			if !ok {
 				fmt.Println("WebSocket closed")
 				return
 			}
 
-			handleMessage(msg)
+			handleEvent(ev)
 
 		case err := <-ws.Errors():
 			log.Printf("WebSocket error: %v", err)
@@ -59,73 +59,52 @@ func main() {
 	}
 }
 
-func handleMessage(msg comfyui.WebSocketMessage) {
-	switch msg.Type {
-	case string(comfyui.MessageTypeStatus):
-		data, err := extractStatusData(msg.Data)
-		if err == nil {
-			fmt.Printf("[STATUS] Queue remaining: %d\n", data.QueueRemaining)
-		}
+func handleEvent(ev comfyui.Event) {
+	switch ev := ev.(type) {
+	case comfyui.StatusEvent:
+		fmt.Printf("[STATUS] Queue remaining: %d\n", ev.QueueRemaining)
 
-	case string(comfyui.MessageTypeExecuting):
-		data, err := msg.GetExecutingData()
-		if err == nil {
-			if data.Node == nil {
-				fmt.Printf("[EXECUTING] Prompt %s completed\n", data.PromptID)
-			} else {
-				fmt.Printf("[EXECUTING] Prompt %s, Node %s\n", data.PromptID, *data.Node)
-			}
-		}
+	case comfyui.ExecutionStartEvent:
+		fmt.Printf("[START] Prompt %s\n", ev.PromptID)
 
-	case string(comfyui.MessageTypeProgress):
-		data, err := msg.GetProgressData()
-		if err == nil {
-			percentage := float64(data.Value) / float64(data.Max) * 100
-			fmt.Printf("[PROGRESS] %d/%d (%.1f%%)\n", data.Value, data.Max, percentage)
+	case comfyui.ExecutingEvent:
+		if ev.Done() {
+			fmt.Printf("[EXECUTING] Prompt %s completed\n", ev.PromptID)
+		} else {
+			fmt.Printf("[EXECUTING] Prompt %s, Node %s\n", ev.PromptID, *ev.Node)
 		}
 
-	case string(comfyui.MessageTypeExecuted):
-		data, err := msg.GetExecutedData()
-		if err == nil {
-			fmt.Printf("[EXECUTED] Node %s in prompt %s\n", data.Node, data.PromptID)
-			if images, ok := data.Output["images"].([]interface{}); ok {
-				fmt.Printf("  → Produced %d image(s)\n", len(images))
-			}
+	case comfyui.ProgressEvent:
+		percentage := float64(ev.Value) / float64(ev.Max) * 100
+		fmt.Printf("[PROGRESS] %d/%d (%.1f%%)\n", ev.Value, ev.Max, percentage)
+
+	case comfyui.ExecutedEvent:
+		fmt.Printf("[EXECUTED] Node %s in prompt %s\n", ev.Node, ev.PromptID)
+		if len(ev.Output.Images) > 0 {
+			fmt.Printf("  → Produced %d image(s)\n", len(ev.Output.Images))
 		}
 
-	case string(comfyui.MessageTypeCached):
-		fmt.Printf("[CACHED] Node execution cached\n")
-
-	case string(comfyui.MessageTypeError):
-		data, err := msg.GetErrorData()
-		if err == nil {
-			fmt.Printf("[ERROR] Prompt %s, Node %s (%s)\n", data.PromptID, data.NodeID, data.NodeType)
-			fmt.Printf("  Type: %s\n", data.ExceptionType)
-			fmt.Printf("  Message: %s\n", data.ExceptionMessage)
-			if len(data.Traceback) > 0 {
-				fmt.Println("  Traceback:")
-				for _, line := range data.Traceback {
-					fmt.Printf("    %s\n", line)
-				}
+	case comfyui.ExecutionCachedEvent:
+		fmt.Printf("[CACHED] %d node(s) reused from cache\n", len(ev.Nodes))
+
+	case comfyui.ExecutionErrorEvent:
+		fmt.Printf("[ERROR] Prompt %s, Node %s (%s)\n", ev.PromptID, ev.NodeID, ev.NodeType)
+		fmt.Printf("  Type: %s\n", ev.ExceptionType)
+		fmt.Printf("  Message: %s\n", ev.ExceptionMessage)
+		if len(ev.Traceback) > 0 {
+			fmt.Println("  Traceback:")
+			for _, line := range ev.Traceback {
+				fmt.Printf("    %s\n", line)
 			}
 		}
 
-	default:
-		fmt.Printf("[%s] %v\n", msg.Type, msg.Data)
-	}
-}
+	case comfyui.ExecutionInterruptedEvent:
+		fmt.Printf("[INTERRUPTED] Prompt %s at node %s\n", ev.PromptID, ev.NodeID)
 
-func extractStatusData(data map[string]interface{}) (*struct{ QueueRemaining int }, error) {
-	result := &struct{ QueueRemaining int }{}
+	case comfyui.ExecutionSuccessEvent:
+		fmt.Printf("[SUCCESS] Prompt %s\n", ev.PromptID)
 
-	if status, ok := data["status"].(map[string]interface{}); ok {
-		if execInfo, ok := status["exec_info"].(map[string]interface{}); ok {
-			if qr, ok := execInfo["queue_remaining"].(float64); ok {
-				result.QueueRemaining = int(qr)
-				return result, nil
-			}
-		}
+	case comfyui.UnknownEvent:
+		fmt.Printf("[%s] %v\n", ev.Type, ev.Data)
 	}
-
-	return nil, fmt.Errorf("invalid status data")
 }
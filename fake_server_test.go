@@ -0,0 +1,165 @@
+package comfyui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeComfyUI is a minimal in-process ComfyUI server for tests
+type fakeComfyUI struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	conns       []*websocket.Conn
+	connected   chan struct{}
+	connections int
+	clientIDs   []string
+	history     map[string]interface{}
+	running     []string
+
+	// onPrompt, if set, is called for every POST /prompt before responding
+	onPrompt func(req QueuePromptRequest)
+
+	// ignorePromptID makes /prompt generate its own ID, like servers that
+	// predate prompt_id
+	ignorePromptID bool
+}
+
+func newFakeComfyUI(t *testing.T) *fakeComfyUI {
+	t.Helper()
+
+	f := &fakeComfyUI{
+		connected: make(chan struct{}, 16),
+		history:   make(map[string]interface{}),
+	}
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, conn)
+		f.connections++
+		f.clientIDs = append(f.clientIDs, r.URL.Query().Get("clientId"))
+		f.mu.Unlock()
+		f.connected <- struct{}{}
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/prompt", func(w http.ResponseWriter, r *http.Request) {
+		var req QueuePromptRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.PromptID == "" || f.ignorePromptID {
+			req.PromptID = "generated-id"
+		}
+		if f.onPrompt != nil {
+			f.onPrompt(req)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"prompt_id":   req.PromptID,
+			"number":      1,
+			"node_errors": map[string]interface{}{},
+		})
+	})
+	mux.HandleFunc("/queue", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		running := make([]interface{}, 0, len(f.running))
+		for i, promptID := range f.running {
+			running = append(running, []interface{}{i, promptID, map[string]interface{}{}, map[string]interface{}{}, []interface{}{}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"queue_running": running,
+			"queue_pending": []interface{}{},
+		})
+	})
+	mux.HandleFunc("/history/", func(w http.ResponseWriter, r *http.Request) {
+		promptID := strings.TrimPrefix(r.URL.Path, "/history/")
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		result := map[string]interface{}{}
+		if item, ok := f.history[promptID]; ok {
+			result[promptID] = item
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// waitConnected blocks until a WebSocket client has connected
+func (f *fakeComfyUI) waitConnected(t *testing.T) {
+	t.Helper()
+	select {
+	case <-f.connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for WebSocket connection")
+	}
+}
+
+// send writes an event to every connected WebSocket client
+func (f *fakeComfyUI) send(msgType string, data map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.WriteJSON(map[string]interface{}{"type": msgType, "data": data})
+	}
+}
+
+// sendRaw writes a raw frame to every connected WebSocket client
+func (f *fakeComfyUI) sendRaw(frameType int, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.WriteMessage(frameType, data)
+	}
+}
+
+// setHistory stores the history entry returned for promptID
+func (f *fakeComfyUI) setHistory(promptID string, item interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.history[promptID] = item
+}
+
+// setRunning replaces the prompt IDs reported as running by /queue
+func (f *fakeComfyUI) setRunning(promptIDs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = promptIDs
+}
+
+// dropConnections closes every WebSocket connection from the server side
+func (f *fakeComfyUI) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+}
+
+func (f *fakeComfyUI) connectionCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connections
+}
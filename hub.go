@@ -0,0 +1,374 @@
+package comfyui
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Subscription delivers the events of a single prompt from the client's
+// shared WebSocket connection. The Events channel is closed once the prompt
+// finishes (an ExecutingEvent with a nil node), when Close is called, or
+// when the client is closed.
+type Subscription struct {
+	hub      *eventHub
+	promptID string
+	events   chan Event
+	done     chan struct{}
+	once     sync.Once
+	closed   bool // guarded by hub.mu
+}
+
+// Events returns the channel of events for the subscribed prompt.
+// Events that are not tied to a prompt (status, reconnected, ...) are
+// delivered to every subscription.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// PromptID returns the subscribed prompt ID, empty for a subscription to all events
+func (s *Subscription) PromptID() string {
+	return s.promptID
+}
+
+// Close unsubscribes from the prompt's events
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.hub.mu.Lock()
+		s.hub.remove(s)
+		s.hub.mu.Unlock()
+	})
+}
+
+// hubIdleTimeout is how long an idle shared connection stays open. ComfyUI
+// unregisters a client ID when the handler of its closed socket finishes,
+// which can happen after a redialed socket registered, so sequential
+// prompts reuse the connection instead of redialing.
+const hubIdleTimeout = 30 * time.Second
+
+// errHubRetired is returned by subscribe when the hub's client ID was replaced
+var errHubRetired = fmt.Errorf("hub retired")
+
+// eventHub multiplexes a single WebSocket connection between subscriptions
+type eventHub struct {
+	client   *Client
+	clientID string // client ID the connection is dialed with
+	mu       sync.Mutex
+	ws       *WebSocketClient
+	subs     map[*Subscription]struct{}
+	dialing  chan struct{} // closed when the current dial finishes
+	gen      int           // incremented by close and retire to discard dials in flight
+	retired  bool          // set once SetClientID replaced the hub
+	idle     *time.Timer   // closes the connection once idle, see hubIdleTimeout
+	idleSeq  int
+	timeout  time.Duration // idle timeout
+}
+
+// Subscribe returns a subscription to the events of promptID, sharing one
+// WebSocket connection between all subscriptions of the client. The
+// connection is opened on first use, reconnects automatically and is closed
+// once it has been idle for a while, or by Close.
+// An empty promptID subscribes to all events.
+func (c *Client) Subscribe(ctx context.Context, promptID string) (*Subscription, error) {
+	for {
+		c.hubMu.Lock()
+		if c.hub == nil {
+			c.hub = &eventHub{
+				client:   c,
+				clientID: c.clientID,
+				subs:     make(map[*Subscription]struct{}),
+				timeout:  c.hubIdleTimeout,
+			}
+		}
+		hub := c.hub
+		c.hubMu.Unlock()
+
+		sub, err := hub.subscribe(ctx, promptID)
+		if err != errHubRetired {
+			return sub, err
+		}
+	}
+}
+
+// Close releases the client's shared WebSocket connection and ends all subscriptions
+func (c *Client) Close() error {
+	c.hubMu.Lock()
+	hubs := c.retiredHubs
+	if c.hub != nil {
+		hubs = append(hubs, c.hub)
+	}
+	c.retiredHubs = nil
+	c.hubMu.Unlock()
+
+	var firstErr error
+	for _, hub := range hubs {
+		if err := hub.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// releaseHub forgets a retired hub whose connection has been closed
+func (c *Client) releaseHub(hub *eventHub) {
+	c.hubMu.Lock()
+	defer c.hubMu.Unlock()
+	for i, h := range c.retiredHubs {
+		if h == hub {
+			c.retiredHubs = append(c.retiredHubs[:i], c.retiredHubs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (h *eventHub) subscribe(ctx context.Context, promptID string) (*Subscription, error) {
+	h.mu.Lock()
+	for {
+		if h.retired {
+			h.mu.Unlock()
+			return nil, errHubRetired
+		}
+		if h.ws != nil {
+			break
+		}
+		if h.dialing != nil {
+			dialing := h.dialing
+			h.mu.Unlock()
+			select {
+			case <-dialing:
+			case <-ctx.Done():
+				return nil, fmt.Errorf("failed to connect websocket: %w", ctx.Err())
+			}
+			h.mu.Lock()
+			continue
+		}
+
+		// Dial without holding the lock, other subscribers wait for the result
+		dialing := make(chan struct{})
+		h.dialing = dialing
+		gen := h.gen
+		h.mu.Unlock()
+		policy := DefaultReconnectPolicy()
+		ws, err := h.client.connectWebSocket(ctx, h.clientID, &policy)
+		h.mu.Lock()
+		h.dialing = nil
+		close(dialing)
+		if err != nil {
+			h.mu.Unlock()
+			return nil, fmt.Errorf("failed to connect websocket: %w", err)
+		}
+		if gen != h.gen {
+			// The hub was closed or retired while dialing
+			ws.Close()
+			continue
+		}
+		h.ws = ws
+		go h.dispatch(ws)
+		go h.drainErrors(ws)
+	}
+	defer h.mu.Unlock()
+
+	if h.idle != nil {
+		h.idle.Stop()
+		h.idle = nil
+	}
+	sub := &Subscription{
+		hub:      h,
+		promptID: promptID,
+		events:   make(chan Event, 100),
+		done:     make(chan struct{}),
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// dispatch routes messages from ws to subscriptions until ws is closed
+func (h *eventHub) dispatch(ws *WebSocketClient) {
+	for msg := range ws.Messages() {
+		if msg.Event == nil {
+			continue
+		}
+		promptID := eventPromptID(msg.Event)
+		terminal := isTerminalEvent(msg.Event)
+
+		h.mu.Lock()
+		for sub := range h.subs {
+			if sub.promptID != "" && promptID != "" && sub.promptID != promptID {
+				continue
+			}
+			h.send(sub, msg.Event, terminal && sub.promptID != "")
+		}
+		h.mu.Unlock()
+	}
+
+	h.mu.Lock()
+	if h.ws != ws {
+		h.mu.Unlock()
+		return
+	}
+	h.ws = nil
+	for sub := range h.subs {
+		h.remove(sub)
+	}
+	retired := h.retired
+	h.mu.Unlock()
+
+	if retired {
+		h.client.releaseHub(h)
+	}
+}
+
+// drainErrors logs the errors of ws until it is closed. The read loop blocks
+// once its error buffer is full, so the hub must keep reading it.
+func (h *eventHub) drainErrors(ws *WebSocketClient) {
+	for err := range ws.Errors() {
+		h.client.log().Warn("websocket error", "error", err)
+	}
+}
+
+// send delivers ev to sub. Terminal events are never dropped and end the
+// subscription; other events are dropped if the subscriber falls behind.
+// Must be called with h.mu held.
+func (h *eventHub) send(sub *Subscription, ev Event, terminal bool) {
+	if !terminal {
+		select {
+		case sub.events <- ev:
+		default:
+			h.client.log().Warn("dropped event for slow subscriber", "type", ev.EventType(), "prompt_id", sub.promptID)
+		}
+		return
+	}
+
+	select {
+	case sub.events <- ev:
+	case <-sub.done:
+	}
+	h.remove(sub)
+}
+
+// remove unregisters sub and closes its channel. Once the last subscription
+// ends the shared connection is closed after the idle timeout, or right away
+// if the hub was retired. Must be called with h.mu held.
+func (h *eventHub) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(h.subs, sub)
+	close(sub.events)
+
+	if len(h.subs) == 0 && h.ws != nil && h.idle == nil {
+		delay := h.timeout
+		if h.retired {
+			delay = 0
+		}
+		h.idleSeq++
+		seq := h.idleSeq
+		h.idle = time.AfterFunc(delay, func() { h.closeIdle(seq) })
+	}
+}
+
+// closeIdle closes the connection if it is still idle since the timer seq was started
+func (h *eventHub) closeIdle(seq int) {
+	h.mu.Lock()
+	if h.idle == nil || h.idleSeq != seq {
+		h.mu.Unlock()
+		return
+	}
+	h.idle = nil
+	ws := h.ws
+	h.ws = nil
+	retired := h.retired
+	h.mu.Unlock()
+
+	if ws != nil {
+		if err := ws.Close(); err != nil {
+			h.client.log().Debug("failed to close idle websocket", "error", err)
+		}
+	}
+	if retired {
+		h.client.releaseHub(h)
+	}
+}
+
+// retire marks the hub as replaced by SetClientID. It reports whether the
+// hub was idle, in which case its connection is closed and the hub can be
+// released right away.
+func (h *eventHub) retire() bool {
+	h.mu.Lock()
+	h.retired = true
+	h.gen++
+	if len(h.subs) > 0 {
+		h.mu.Unlock()
+		return false
+	}
+	if h.idle != nil {
+		h.idle.Stop()
+		h.idle = nil
+	}
+	ws := h.ws
+	h.ws = nil
+	h.mu.Unlock()
+
+	if ws != nil {
+		ws.Close()
+	}
+	return true
+}
+
+func (h *eventHub) close() error {
+	h.mu.Lock()
+	ws := h.ws
+	h.ws = nil
+	h.gen++
+	if h.idle != nil {
+		h.idle.Stop()
+		h.idle = nil
+	}
+	for sub := range h.subs {
+		h.remove(sub)
+	}
+	h.mu.Unlock()
+
+	if ws == nil {
+		return nil
+	}
+	return ws.Close()
+}
+
+// eventPromptID returns the prompt an event belongs to, or "" for connection-level events
+func eventPromptID(ev Event) string {
+	switch e := ev.(type) {
+	case ExecutionStartEvent:
+		return e.PromptID
+	case ExecutingEvent:
+		return e.PromptID
+	case ProgressEvent:
+		return e.PromptID
+	case ExecutedEvent:
+		return e.PromptID
+	case ExecutionCachedEvent:
+		return e.PromptID
+	case ExecutionErrorEvent:
+		return e.PromptID
+	case ExecutionInterruptedEvent:
+		return e.PromptID
+	case ExecutionSuccessEvent:
+		return e.PromptID
+	case UnknownEvent:
+		pid, _ := e.Data["prompt_id"].(string)
+		return pid
+	}
+	return ""
+}
+
+// isTerminalEvent reports whether ev is the last event ComfyUI sends for a prompt.
+// ComfyUI always finishes with an executing message for a null node, including
+// after errors and interrupts.
+func isTerminalEvent(ev Event) bool {
+	e, ok := ev.(ExecutingEvent)
+	return ok && e.Done()
+}
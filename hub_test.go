@@ -0,0 +1,417 @@
+package comfyui
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscribeMultiplexesPrompts(t *testing.T) {
+	server := newFakeComfyUI(t)
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subA, err := client.Subscribe(ctx, "prompt-a")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	subB, err := client.Subscribe(ctx, "prompt-b")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	server.waitConnected(t)
+
+	server.send("progress", map[string]interface{}{"prompt_id": "prompt-a", "node": "3", "value": 1, "max": 2})
+	server.send("progress", map[string]interface{}{"prompt_id": "prompt-b", "node": "3", "value": 2, "max": 2})
+	server.send("executing", map[string]interface{}{"prompt_id": "prompt-a", "node": nil})
+	server.send("executing", map[string]interface{}{"prompt_id": "prompt-b", "node": nil})
+
+	for _, sub := range []*Subscription{subA, subB} {
+		var events []Event
+		for ev := range sub.Events() {
+			if pid := eventPromptID(ev); pid != sub.PromptID() {
+				t.Errorf("Subscription %s received event for %s", sub.PromptID(), pid)
+			}
+			events = append(events, ev)
+		}
+		if len(events) != 2 {
+			t.Errorf("Subscription %s: expected 2 events, got %d", sub.PromptID(), len(events))
+		}
+	}
+
+	if n := server.connectionCount(); n != 1 {
+		t.Errorf("Expected a single shared connection, got %d", n)
+	}
+}
+
+func TestSubscribeSurvivesDecodeErrors(t *testing.T) {
+	server := newFakeComfyUI(t)
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := client.Subscribe(ctx, "prompt-a")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	server.waitConnected(t)
+
+	// More bad frames than the error buffer holds
+	for i := 0; i < 12; i++ {
+		server.sendRaw(websocket.TextMessage, []byte("{not json"))
+	}
+	server.send("executing", map[string]interface{}{"prompt_id": "prompt-a", "node": nil})
+
+	select {
+	case ev, ok := <-sub.Events():
+		if !ok || !isTerminalEvent(ev) {
+			t.Errorf("Expected completion event, got %v (open %v)", ev, ok)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for completion after decode errors")
+	}
+}
+
+func TestSharedConnectionClosedWhenIdle(t *testing.T) {
+	server := newFakeComfyUI(t)
+	client := NewClient(server.URL)
+	client.hubIdleTimeout = 100 * time.Millisecond
+	defer client.Close()
+
+	first, err := client.Subscribe(context.Background(), "prompt-a")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	server.waitConnected(t)
+	ws := client.hub.ws
+	first.Close()
+
+	// A subscription within the idle timeout reuses the connection
+	second, err := client.Subscribe(context.Background(), "prompt-b")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	second.Close()
+	if n := server.connectionCount(); n != 1 {
+		t.Errorf("Expected the connection to be reused, got %d connections", n)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !ws.closed() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the idle connection to be closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The next subscription dials again
+	third, err := client.Subscribe(context.Background(), "prompt-c")
+	if err != nil {
+		t.Fatalf("Failed to subscribe again: %v", err)
+	}
+	defer third.Close()
+	server.waitConnected(t)
+	if n := server.connectionCount(); n != 2 {
+		t.Errorf("Expected a new connection, got %d in total", n)
+	}
+}
+
+func TestSequentialRunsShareConnection(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.onPrompt = func(req QueuePromptRequest) {
+		server.setHistory(req.PromptID, successHistory(req.PromptID))
+		server.send("executing", map[string]interface{}{"prompt_id": req.PromptID, "node": nil})
+	}
+
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Run(ctx, Workflow{"9": Node{ClassType: "SaveImage"}}, RunOptions{}); err != nil {
+			t.Fatalf("Run %d failed: %v", i, err)
+		}
+	}
+	if n := server.connectionCount(); n != 1 {
+		t.Errorf("Expected sequential runs to share one connection, got %d", n)
+	}
+}
+
+func TestSetClientIDReconnectsSubscriptions(t *testing.T) {
+	server := newFakeComfyUI(t)
+	client := NewClient(server.URL, WithClientID("first"))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	old, err := client.Subscribe(ctx, "prompt-a")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	server.waitConnected(t)
+
+	client.SetClientID("second")
+	sub, err := client.Subscribe(ctx, "prompt-b")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	server.waitConnected(t)
+
+	server.mu.Lock()
+	clientIDs := append([]string(nil), server.clientIDs...)
+	server.mu.Unlock()
+	if len(clientIDs) != 2 || clientIDs[0] != "first" || clientIDs[1] != "second" {
+		t.Fatalf("Expected a connection per client ID, got %v", clientIDs)
+	}
+
+	// Both subscriptions still receive their prompts' events
+	server.send("executing", map[string]interface{}{"prompt_id": "prompt-a", "node": nil})
+	server.send("executing", map[string]interface{}{"prompt_id": "prompt-b", "node": nil})
+	for _, s := range []*Subscription{old, sub} {
+		select {
+		case ev, ok := <-s.Events():
+			if !ok || !isTerminalEvent(ev) {
+				t.Errorf("Subscription %s: expected completion, got %v", s.PromptID(), ev)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Subscription %s timed out", s.PromptID())
+		}
+	}
+}
+
+func TestSetClientIDDuringSubscribe(t *testing.T) {
+	server := newFakeComfyUI(t)
+	client := NewClient(server.URL, WithClientID("id-0"))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sub, err := client.Subscribe(ctx, fmt.Sprintf("prompt-%d", i))
+			if err != nil {
+				t.Errorf("Failed to subscribe: %v", err)
+				return
+			}
+			sub.Close()
+		}(i)
+		client.SetClientID(fmt.Sprintf("id-%d", i+1))
+	}
+	wg.Wait()
+
+	// Every connection was dialed with the ID of its hub, once per ID
+	server.mu.Lock()
+	clientIDs := append([]string(nil), server.clientIDs...)
+	server.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, id := range clientIDs {
+		if seen[id] {
+			t.Errorf("Client ID %s connected twice: %v", id, clientIDs)
+		}
+		seen[id] = true
+	}
+
+	// Retired hubs are released once their subscriptions ended
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		client.hubMu.Lock()
+		retired := len(client.retiredHubs)
+		client.hubMu.Unlock()
+		if retired == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected retired hubs to be released, %d left", retired)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	server := newFakeComfyUI(t)
+	client := NewClient(server.URL)
+
+	sub, err := client.Subscribe(context.Background(), "prompt-a")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	sub.Close()
+	sub.Close()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Error("Expected events channel to be closed after Close")
+	}
+
+	all, err := client.Subscribe(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	client.Close()
+	if _, ok := <-all.Events(); ok {
+		t.Error("Expected events channel to be closed after client Close")
+	}
+}
+
+func TestWaitForCompletionSharedConnection(t *testing.T) {
+	server := newFakeComfyUI(t)
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results := make(chan *ExecutionResult, 2)
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			result, err := client.WaitForCompletion(ctx, "prompt-a")
+			results <- result
+			errs <- err
+		}()
+	}
+
+	server.waitConnected(t)
+	waitSubscriptions(t, client, 2)
+	server.setHistory("prompt-a", successHistory("prompt-a"))
+	server.send("executing", map[string]interface{}{"prompt_id": "prompt-a", "node": nil})
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("WaitForCompletion failed: %v", err)
+		}
+		result := <-results
+		if len(result.Images) != 1 || result.Images[0].Filename != "out.png" {
+			t.Errorf("Unexpected images: %+v", result.Images)
+		}
+	}
+
+	if n := server.connectionCount(); n != 1 {
+		t.Errorf("Expected a single shared connection, got %d", n)
+	}
+}
+
+// waitSubscriptions blocks until the client has n active subscriptions
+func waitSubscriptions(t *testing.T, client *Client, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		client.hubMu.Lock()
+		hub := client.hub
+		client.hubMu.Unlock()
+		if hub != nil {
+			hub.mu.Lock()
+			count := len(hub.subs)
+			hub.mu.Unlock()
+			if count == n {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d subscriptions", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// successHistory returns a history entry for a finished prompt with one output image
+func successHistory(promptID string) map[string]interface{} {
+	return map[string]interface{}{
+		"prompt": []interface{}{1, promptID, map[string]interface{}{}, map[string]interface{}{}, []interface{}{"9"}},
+		"outputs": map[string]interface{}{
+			"9": map[string]interface{}{
+				"images": []interface{}{map[string]interface{}{"filename": "out.png", "subfolder": "", "type": "output"}},
+			},
+		},
+		"status": map[string]interface{}{"status_str": "success", "completed": true},
+	}
+}
+
+func TestRunCatchesImmediateCompletion(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.onPrompt = func(req QueuePromptRequest) {
+		// A fully cached workflow finishes before /prompt even responds
+		server.setHistory(req.PromptID, successHistory(req.PromptID))
+		server.send("execution_cached", map[string]interface{}{"prompt_id": req.PromptID, "nodes": []string{"9"}})
+		server.send("executing", map[string]interface{}{"prompt_id": req.PromptID, "node": nil})
+	}
+
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := client.Run(ctx, Workflow{"9": Node{ClassType: "SaveImage"}}, RunOptions{PromptID: "fixed-id"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.PromptID != "fixed-id" {
+		t.Errorf("Expected prompt ID fixed-id, got %s", result.PromptID)
+	}
+	if len(result.Images) != 1 || !result.Status.Completed {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestWaitForCompletionAlreadyFinished(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.setHistory("done-id", successHistory("done-id"))
+
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := client.WaitForCompletion(ctx, "done-id")
+	if err != nil {
+		t.Fatalf("WaitForCompletion failed: %v", err)
+	}
+	if len(result.Images) != 1 {
+		t.Errorf("Expected 1 image, got %d", len(result.Images))
+	}
+}
+
+func TestRunRecoversCompletionMissedDuringDisconnect(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.onPrompt = func(req QueuePromptRequest) {
+		go func() {
+			// The connection drops and the prompt finishes while disconnected
+			server.dropConnections()
+			server.setHistory(req.PromptID, successHistory(req.PromptID))
+		}()
+	}
+
+	client := NewClient(server.URL)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := client.Run(ctx, Workflow{"9": Node{ClassType: "SaveImage"}}, RunOptions{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.Images) != 1 {
+		t.Errorf("Expected 1 image, got %d", len(result.Images))
+	}
+	if n := server.connectionCount(); n != 2 {
+		t.Errorf("Expected 2 connections, got %d", n)
+	}
+}
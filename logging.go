@@ -0,0 +1,47 @@
+package comfyui
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SetLogger sets the logger for the client and the WebSocket connections it
+// opens afterwards. Requests and WebSocket messages are logged at debug
+// level, connection changes at info and failures at warn. Nothing is logged
+// by default.
+func (c *Client) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	c.logger = logger
+}
+
+// log returns the client's logger with its client ID attached
+func (c *Client) log() *slog.Logger {
+	return c.logger.With("client_id", c.GetClientID())
+}
+
+// logResponse logs the outcome of a single HTTP request
+func (c *Client) logResponse(req *http.Request, resp *http.Response, err error, latency time.Duration) {
+	ctx := req.Context()
+	logger := c.log().With("method", req.Method, "path", c.endpointPath(req.URL.Path), "latency", latency)
+
+	switch {
+	case err != nil:
+		logger.WarnContext(ctx, "http request failed", "error", err)
+	case resp.StatusCode >= 500:
+		logger.WarnContext(ctx, "http response", "status", resp.StatusCode)
+	default:
+		logger.DebugContext(ctx, "http response", "status", resp.StatusCode)
+	}
+}
+
+// discardHandler is a slog.Handler that drops all records
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
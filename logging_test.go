@@ -0,0 +1,100 @@
+package comfyui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logRecorder collects JSON log records for assertions
+type logRecorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *logRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+// find returns the first record with the given message
+func (r *logRecorder) find(msg string) map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range bytes.Split(r.buf.Bytes(), []byte("\n")) {
+		var record map[string]interface{}
+		if json.Unmarshal(line, &record) == nil && record["msg"] == msg {
+			return record
+		}
+	}
+	return nil
+}
+
+func newTestLogger() (*slog.Logger, *logRecorder) {
+	recorder := &logRecorder{}
+	return slog.New(slog.NewJSONHandler(recorder, &slog.HandlerOptions{Level: slog.LevelDebug})), recorder
+}
+
+func TestClientLogging(t *testing.T) {
+	server := newFakeComfyUI(t)
+	logger, logs := newTestLogger()
+	client := NewClient(server.URL, WithLogger(logger), WithClientID("cid"))
+
+	if _, err := client.QueuePromptWithID(context.Background(), "pid", Workflow{}, nil); err != nil {
+		t.Fatalf("QueuePromptWithID failed: %v", err)
+	}
+
+	request := logs.find("http request")
+	if request == nil || request["method"] != "POST" || request["path"] != "/prompt" || request["client_id"] != "cid" {
+		t.Errorf("Unexpected request record: %v", request)
+	}
+	response := logs.find("http response")
+	if response == nil || response["status"] != float64(200) || response["latency"] == nil {
+		t.Errorf("Unexpected response record: %v", response)
+	}
+	queued := logs.find("prompt queued")
+	if queued == nil || queued["prompt_id"] != "pid" || queued["level"] != "INFO" {
+		t.Errorf("Unexpected queued record: %v", queued)
+	}
+}
+
+func TestWebSocketLogging(t *testing.T) {
+	server := newFakeComfyUI(t)
+	logger, logs := newTestLogger()
+	client := NewClient(server.URL, WithLogger(logger))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ws, err := client.ConnectWebSocket(ctx)
+	if err != nil {
+		t.Fatalf("ConnectWebSocket failed: %v", err)
+	}
+	server.waitConnected(t)
+
+	server.send("executing", map[string]interface{}{"prompt_id": "pid", "node": "3"})
+	server.dropConnections()
+	for range ws.Messages() {
+	}
+	ws.Close()
+
+	if record := logs.find("websocket connected"); record == nil || record["client_id"] != client.GetClientID() {
+		t.Errorf("Unexpected connect record: %v", record)
+	}
+	if record := logs.find("websocket message"); record == nil || record["prompt_id"] != "pid" {
+		t.Errorf("Unexpected message record: %v", record)
+	}
+	if record := logs.find("websocket disconnected"); record == nil {
+		t.Error("Expected disconnect to be logged")
+	}
+}
+
+func TestSetLoggerNil(t *testing.T) {
+	client := NewClient("http://127.0.0.1:8188")
+	client.SetLogger(nil)
+	client.log().Info("discarded")
+}
@@ -0,0 +1,273 @@
+package comfyui
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsCollector receives measurements from a Client. Implementations must
+// be safe for concurrent use. Prompt metrics other than PromptExecuted are
+// only reported by Run and WaitForCompletion using WebSocketCompletion.
+type MetricsCollector interface {
+	// RequestCompleted is called for each HTTP attempt. endpoint is the first
+	// path segment, e.g. /history; status is 0 if no response was received.
+	RequestCompleted(method, endpoint string, status int, latency time.Duration)
+	// PromptQueueLatency is the time from queueing to execution_start,
+	// reported by Run only
+	PromptQueueLatency(workflow string, latency time.Duration)
+	// PromptExecuted is called when Run or WaitForCompletion returns.
+	// status is success, error or interrupted.
+	PromptExecuted(workflow, status string, duration time.Duration)
+	// NodeExecuted is the execution time of a node that was not cached.
+	// classType is empty if the workflow is unknown.
+	NodeExecuted(classType string, duration time.Duration)
+	// NodesCached reports how many of a prompt's nodes were cached
+	NodesCached(cached, total int)
+	// WebSocketReconnected is called after each successful reconnect
+	WebSocketReconnected()
+	// BytesDownloaded counts image bytes downloaded by GetImage
+	BytesDownloaded(n int64)
+}
+
+// WithMetrics sets the collector that receives the client's metrics
+func WithMetrics(collector MetricsCollector) Option {
+	return func(o *clientOptions) {
+		o.metrics = collector
+	}
+}
+
+// endpointLabel returns the first segment of an endpoint path, so that
+// prompt IDs and class names do not end up in metric labels
+func endpointLabel(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		path = path[:i]
+	}
+	return "/" + path
+}
+
+// DefaultLatencyBuckets are the histogram buckets for request latencies, in seconds
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultDurationBuckets are the histogram buckets for queue and execution times, in seconds
+var DefaultDurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+// InMemoryMetrics is a MetricsCollector that keeps counters and histograms
+// in memory and serves them in the Prometheus text exposition format
+type InMemoryMetrics struct {
+	mu                sync.Mutex
+	requests          map[string]uint64 // by method, endpoint, status labels
+	requestLatency    map[string]*histogram
+	queueLatency      map[string]*histogram
+	executionDuration map[string]*histogram
+	nodeDuration      map[string]*histogram
+	cachedNodes       uint64
+	totalNodes        uint64
+	reconnects        uint64
+	bytesDownloaded   uint64
+}
+
+// NewInMemoryMetrics creates an empty in-memory collector
+func NewInMemoryMetrics() *InMemoryMetrics {
+	return &InMemoryMetrics{
+		requests:          make(map[string]uint64),
+		requestLatency:    make(map[string]*histogram),
+		queueLatency:      make(map[string]*histogram),
+		executionDuration: make(map[string]*histogram),
+		nodeDuration:      make(map[string]*histogram),
+	}
+}
+
+// RequestCompleted implements MetricsCollector
+func (m *InMemoryMetrics) RequestCompleted(method, endpoint string, status int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[labels("method", method, "endpoint", endpoint, "status", strconv.Itoa(status))]++
+	observe(m.requestLatency, labels("method", method, "endpoint", endpoint), DefaultLatencyBuckets, latency)
+}
+
+// PromptQueueLatency implements MetricsCollector
+func (m *InMemoryMetrics) PromptQueueLatency(workflow string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observe(m.queueLatency, labels("workflow", workflow), DefaultDurationBuckets, latency)
+}
+
+// PromptExecuted implements MetricsCollector
+func (m *InMemoryMetrics) PromptExecuted(workflow, status string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observe(m.executionDuration, labels("workflow", workflow, "status", status), DefaultDurationBuckets, duration)
+}
+
+// NodeExecuted implements MetricsCollector
+func (m *InMemoryMetrics) NodeExecuted(classType string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observe(m.nodeDuration, labels("class_type", classType), DefaultDurationBuckets, duration)
+}
+
+// NodesCached implements MetricsCollector
+func (m *InMemoryMetrics) NodesCached(cached, total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cachedNodes += uint64(cached)
+	m.totalNodes += uint64(total)
+}
+
+// WebSocketReconnected implements MetricsCollector
+func (m *InMemoryMetrics) WebSocketReconnected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconnects++
+}
+
+// BytesDownloaded implements MetricsCollector
+func (m *InMemoryMetrics) BytesDownloaded(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesDownloaded += uint64(n)
+}
+
+// CacheHitRatio returns the fraction of nodes that were cached, or 0 if no prompts were recorded
+func (m *InMemoryMetrics) CacheHitRatio() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cacheHitRatio()
+}
+
+func (m *InMemoryMetrics) cacheHitRatio() float64 {
+	if m.totalNodes == 0 {
+		return 0
+	}
+	return float64(m.cachedNodes) / float64(m.totalNodes)
+}
+
+// Handler returns an http.Handler serving the metrics in the Prometheus text format
+func (m *InMemoryMetrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *InMemoryMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	writeCounters(&b, "comfyui_http_requests_total", "HTTP requests by method, endpoint and status.", m.requests)
+	writeHistograms(&b, "comfyui_http_request_duration_seconds", "HTTP request latency.", m.requestLatency)
+	writeHistograms(&b, "comfyui_prompt_queue_latency_seconds", "Time from queueing a prompt to the start of its execution.", m.queueLatency)
+	writeHistograms(&b, "comfyui_prompt_execution_duration_seconds", "Time from queueing or waiting for a prompt until it finished.", m.executionDuration)
+	writeHistograms(&b, "comfyui_node_execution_duration_seconds", "Execution time of nodes by class type.", m.nodeDuration)
+	writeCounters(&b, "comfyui_nodes_cached_total", "Nodes served from the cache.", map[string]uint64{"": m.cachedNodes})
+	writeCounters(&b, "comfyui_nodes_total", "Nodes in observed prompts.", map[string]uint64{"": m.totalNodes})
+	writeMetric(&b, "comfyui_cache_hit_ratio", "Fraction of nodes served from the cache.", "gauge")
+	fmt.Fprintf(&b, "comfyui_cache_hit_ratio %s\n", formatFloat(m.cacheHitRatio()))
+	writeCounters(&b, "comfyui_websocket_reconnects_total", "WebSocket reconnects.", map[string]uint64{"": m.reconnects})
+	writeCounters(&b, "comfyui_downloaded_bytes_total", "Image bytes downloaded.", map[string]uint64{"": m.bytesDownloaded})
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// histogram is a cumulative Prometheus-style histogram
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // counts[i] observations <= buckets[i]
+	sum     float64
+	count   uint64
+}
+
+// observe records d in seconds in the histogram for key, creating it if needed
+func observe(histograms map[string]*histogram, key string, buckets []float64, d time.Duration) {
+	h, ok := histograms[key]
+	if !ok {
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		histograms[key] = h
+	}
+
+	value := d.Seconds()
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// labels formats label pairs as a Prometheus label set without braces
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escapeLabel(pairs[i+1])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func writeMetric(b *strings.Builder, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeCounters(b *strings.Builder, name, help string, counters map[string]uint64) {
+	writeMetric(b, name, help, "counter")
+	for _, key := range sortedKeys(counters) {
+		fmt.Fprintf(b, "%s%s %d\n", name, braces(key), counters[key])
+	}
+}
+
+func writeHistograms(b *strings.Builder, name, help string, histograms map[string]*histogram) {
+	writeMetric(b, name, help, "histogram")
+	for _, key := range sortedKeys(histograms) {
+		h := histograms[key]
+		prefix := key
+		if prefix != "" {
+			prefix += ","
+		}
+		for i, bound := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket{%sle=\"%s\"} %d\n", name, prefix, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", name, braces(key), formatFloat(h.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", name, braces(key), h.count)
+	}
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,161 @@
+package comfyui
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInMemoryMetricsExposition(t *testing.T) {
+	m := NewInMemoryMetrics()
+	m.RequestCompleted("GET", "/history", 200, 30*time.Millisecond)
+	m.RequestCompleted("GET", "/history", 200, 2*time.Second)
+	m.RequestCompleted("POST", "/prompt", 0, time.Millisecond)
+	m.NodeExecuted("KSampler", 3*time.Second)
+	m.PromptExecuted(`my "flow"`, "success", 4*time.Second)
+	m.NodesCached(1, 4)
+	m.WebSocketReconnected()
+	m.BytesDownloaded(1024)
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Unexpected content type %s", resp.Header.Get("Content-Type"))
+	}
+	expected := []string{
+		"# TYPE comfyui_http_requests_total counter",
+		`comfyui_http_requests_total{method="GET",endpoint="/history",status="200"} 2`,
+		`comfyui_http_requests_total{method="POST",endpoint="/prompt",status="0"} 1`,
+		"# TYPE comfyui_http_request_duration_seconds histogram",
+		`comfyui_http_request_duration_seconds_bucket{method="GET",endpoint="/history",le="0.05"} 1`,
+		`comfyui_http_request_duration_seconds_bucket{method="GET",endpoint="/history",le="+Inf"} 2`,
+		`comfyui_http_request_duration_seconds_count{method="GET",endpoint="/history"} 2`,
+		`comfyui_node_execution_duration_seconds_sum{class_type="KSampler"} 3`,
+		`comfyui_prompt_execution_duration_seconds_count{workflow="my \"flow\"",status="success"} 1`,
+		"comfyui_cache_hit_ratio 0.25",
+		"comfyui_websocket_reconnects_total 1",
+		"comfyui_downloaded_bytes_total 1024",
+	}
+	for _, line := range expected {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Missing line %q in:\n%s", line, text)
+		}
+	}
+}
+
+func TestEndpointLabel(t *testing.T) {
+	for path, want := range map[string]string{
+		"/history/abc-123":      "/history",
+		"/object_info/KSampler": "/object_info",
+		"/prompt":               "/prompt",
+		"/":                     "/",
+	} {
+		if got := endpointLabel(path); got != want {
+			t.Errorf("endpointLabel(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRunCollectsMetrics(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.onPrompt = func(req QueuePromptRequest) {
+		pid := req.PromptID
+		server.setHistory(pid, successHistory(pid))
+		server.send("execution_start", map[string]interface{}{"prompt_id": pid})
+		server.send("execution_cached", map[string]interface{}{"prompt_id": pid, "nodes": []string{"4"}})
+		server.send("executing", map[string]interface{}{"prompt_id": pid, "node": "3"})
+		server.send("executing", map[string]interface{}{"prompt_id": pid, "node": "9"})
+		server.send("executed", map[string]interface{}{"prompt_id": pid, "node": "9", "output": map[string]interface{}{}})
+		server.send("executing", map[string]interface{}{"prompt_id": pid, "node": nil})
+	}
+
+	metrics := NewInMemoryMetrics()
+	client := NewClient(server.URL, WithMetrics(metrics))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	workflow := Workflow{
+		"3": Node{ClassType: "KSampler"},
+		"4": Node{ClassType: "CheckpointLoaderSimple"},
+		"9": Node{ClassType: "SaveImage"},
+		"7": Node{ClassType: "CLIPTextEncode"},
+	}
+	if _, err := client.Run(ctx, workflow, RunOptions{Name: "txt2img"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var b strings.Builder
+	metrics.WritePrometheus(&b)
+	text := b.String()
+	for _, line := range []string{
+		`comfyui_http_requests_total{method="POST",endpoint="/prompt",status="200"} 1`,
+		`comfyui_prompt_queue_latency_seconds_count{workflow="txt2img"} 1`,
+		`comfyui_prompt_execution_duration_seconds_count{workflow="txt2img",status="success"} 1`,
+		`comfyui_node_execution_duration_seconds_count{class_type="KSampler"} 1`,
+		`comfyui_node_execution_duration_seconds_count{class_type="SaveImage"} 1`,
+		"comfyui_nodes_cached_total 1",
+		"comfyui_nodes_total 4",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Missing line %q in:\n%s", line, text)
+		}
+	}
+	if ratio := metrics.CacheHitRatio(); ratio != 0.25 {
+		t.Errorf("Expected cache hit ratio 0.25, got %v", ratio)
+	}
+}
+
+func TestMetricsDownloadsAndReconnects(t *testing.T) {
+	server := newFakeComfyUI(t)
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer images.Close()
+
+	metrics := NewInMemoryMetrics()
+	if _, err := NewClient(images.URL, WithMetrics(metrics)).GetImage(context.Background(), "a.png", "", "output"); err != nil {
+		t.Fatalf("GetImage failed: %v", err)
+	}
+
+	client := NewClient(server.URL, WithMetrics(metrics))
+	policy := ReconnectPolicy{InitialBackoff: time.Millisecond}
+	ws, err := client.ConnectWebSocketWithReconnect(context.Background(), policy)
+	if err != nil {
+		t.Fatalf("ConnectWebSocketWithReconnect failed: %v", err)
+	}
+	defer ws.Close()
+	server.waitConnected(t)
+	server.dropConnections()
+
+	timeout := time.After(5 * time.Second)
+	for reconnected := false; !reconnected; {
+		select {
+		case msg := <-ws.Messages():
+			reconnected = msg.Type == string(MessageTypeReconnected)
+		case <-timeout:
+			t.Fatal("Timed out waiting for reconnect")
+		}
+	}
+
+	var b strings.Builder
+	metrics.WritePrometheus(&b)
+	for _, line := range []string{"comfyui_downloaded_bytes_total 10", "comfyui_websocket_reconnects_total 1"} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Missing line %q", line)
+		}
+	}
+}
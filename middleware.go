@@ -0,0 +1,129 @@
+package comfyui
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps the transport used for every HTTP request made by the
+// client, including uploads and image downloads. It sees each attempt
+// separately when requests are retried, after credentials have been added.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// RequestInterceptor is called before each HTTP request is sent. It may
+// modify the request, e.g. to sign it; returning an error aborts the request.
+type RequestInterceptor func(req *http.Request) error
+
+// ResponseInfo describes a completed HTTP request
+type ResponseInfo struct {
+	Method       string
+	Path         string // endpoint path, e.g. /prompt, without the base URL prefix
+	StatusCode   int    // 0 if the request failed
+	RequestSize  int64  // request body size, -1 if unknown
+	ResponseSize int64  // response body size from Content-Length, -1 if unknown
+	Latency      time.Duration
+	Err          error // transport error, nil for any HTTP response
+}
+
+// ResponseInterceptor is called after each HTTP request completes
+type ResponseInterceptor func(req *http.Request, info ResponseInfo)
+
+// MessageInterceptor is called for every inbound WebSocket message before it
+// is delivered. It may modify the message; returning false drops it. Dropping
+// messages that Run and WaitForCompletion rely on will stall them.
+type MessageInterceptor func(msg *WebSocketMessage) bool
+
+// WithMiddleware adds middleware around the HTTP transport. The first
+// middleware is the outermost.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *clientOptions) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithRequestInterceptor adds a function called before each HTTP request
+func WithRequestInterceptor(interceptor RequestInterceptor) Option {
+	return func(o *clientOptions) {
+		o.requestInterceptors = append(o.requestInterceptors, interceptor)
+	}
+}
+
+// WithResponseInterceptor adds a function called after each HTTP request
+func WithResponseInterceptor(interceptor ResponseInterceptor) Option {
+	return func(o *clientOptions) {
+		o.responseInterceptors = append(o.responseInterceptors, interceptor)
+	}
+}
+
+// WithMessageInterceptor adds a function called for each inbound WebSocket message
+func WithMessageInterceptor(interceptor MessageInterceptor) Option {
+	return func(o *clientOptions) {
+		o.messageInterceptors = append(o.messageInterceptors, interceptor)
+	}
+}
+
+// buildTransport chains the middleware around the HTTP client
+func (o *clientOptions) buildTransport(httpClient *http.Client) http.RoundTripper {
+	var transport http.RoundTripper = RoundTripperFunc(httpClient.Do)
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		transport = o.middleware[i](transport)
+	}
+	return transport
+}
+
+// buildMessageInterceptor combines the message interceptors into one
+func (o *clientOptions) buildMessageInterceptor() MessageInterceptor {
+	if len(o.messageInterceptors) == 0 {
+		return nil
+	}
+	chain := o.messageInterceptors
+	return func(msg *WebSocketMessage) bool {
+		for _, intercept := range chain {
+			if !intercept(msg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// roundTrip sends req through the middleware chain, calling the interceptors around it
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	for _, intercept := range c.requestInterceptors {
+		if err := intercept(req); err != nil {
+			return nil, fmt.Errorf("request interceptor failed: %w", err)
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.transport.RoundTrip(req)
+	if len(c.responseInterceptors) == 0 {
+		return resp, err
+	}
+
+	info := ResponseInfo{
+		Method:       req.Method,
+		Path:         c.endpointPath(req.URL.Path),
+		RequestSize:  req.ContentLength,
+		ResponseSize: -1,
+		Latency:      time.Since(start),
+		Err:          err,
+	}
+	if resp != nil {
+		info.StatusCode = resp.StatusCode
+		info.ResponseSize = resp.ContentLength
+	}
+	for _, intercept := range c.responseInterceptors {
+		intercept(req, info)
+	}
+	return resp, err
+}
@@ -0,0 +1,144 @@
+package comfyui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signature") != "signed" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	client := NewClient(server.URL,
+		WithMiddleware(trace("outer"), trace("inner")),
+		WithRequestInterceptor(func(req *http.Request) error {
+			order = append(order, "request")
+			req.Header.Set("X-Signature", "signed")
+			return nil
+		}),
+	)
+
+	if _, err := client.GetHistory(context.Background(), ""); err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "request,outer,inner" {
+		t.Errorf("Unexpected call order %s", got)
+	}
+}
+
+func TestResponseInterceptor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/comfy/view":
+			w.Write([]byte("image-bytes"))
+		case "/comfy/upload/image":
+			w.Write([]byte(`{"name": "a.png"}`))
+		default:
+			w.Write([]byte(`{"prompt_id": "p", "number": 1}`))
+		}
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	infos := make(map[string]ResponseInfo)
+	client := NewClient(server.URL+"/comfy", WithResponseInterceptor(func(req *http.Request, info ResponseInfo) {
+		mu.Lock()
+		infos[info.Method+" "+info.Path] = info
+		mu.Unlock()
+	}))
+	ctx := context.Background()
+
+	client.QueuePrompt(ctx, Workflow{}, nil)
+	client.UploadImageBytes(ctx, []byte("data"), "a.png", UploadOptions{})
+	client.GetImage(ctx, "a.png", "", "output")
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, key := range []string{"POST /prompt", "POST /upload/image", "GET /view"} {
+		info, ok := infos[key]
+		if !ok {
+			t.Errorf("No response info for %s, got %v", key, infos)
+			continue
+		}
+		if info.StatusCode != http.StatusOK || info.Latency < 10*time.Millisecond || info.Err != nil {
+			t.Errorf("%s: unexpected info %+v", key, info)
+		}
+	}
+	if info := infos["POST /prompt"]; info.RequestSize <= 0 {
+		t.Errorf("Expected request size for POST /prompt, got %d", info.RequestSize)
+	}
+	if info := infos["GET /view"]; info.RequestSize != 0 || info.ResponseSize != int64(len("image-bytes")) {
+		t.Errorf("Unexpected sizes for GET /view: %+v", info)
+	}
+}
+
+func TestRequestInterceptorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request should not be sent")
+	}))
+	defer server.Close()
+
+	failure := errors.New("signing key unavailable")
+	client := NewClient(server.URL, WithRequestInterceptor(func(req *http.Request) error {
+		return failure
+	}))
+	if _, err := client.GetQueue(context.Background()); !errors.Is(err, failure) {
+		t.Errorf("Expected interceptor error, got %v", err)
+	}
+}
+
+func TestMessageInterceptor(t *testing.T) {
+	server := newFakeComfyUI(t)
+	client := NewClient(server.URL, WithMessageInterceptor(func(msg *WebSocketMessage) bool {
+		if msg.Type == "crystools.monitor" {
+			return false
+		}
+		msg.Data["seen"] = true
+		return true
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ws, err := client.ConnectWebSocket(ctx)
+	if err != nil {
+		t.Fatalf("ConnectWebSocket failed: %v", err)
+	}
+	defer ws.Close()
+	server.waitConnected(t)
+
+	server.send("crystools.monitor", map[string]interface{}{"cpu": 10})
+	server.send("status", map[string]interface{}{"status": map[string]interface{}{}})
+
+	select {
+	case msg := <-ws.Messages():
+		if msg.Type != "status" || msg.Data["seen"] != true {
+			t.Errorf("Unexpected message: %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for message")
+	}
+}
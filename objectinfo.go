@@ -0,0 +1,333 @@
+package comfyui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// UnmarshalJSON decodes the input maps and remembers the order in which
+// inputs are declared, which determines the order of a node's widgets
+func (n *NodeInputInfo) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Required json.RawMessage `json:"required"`
+		Optional json.RawMessage `json:"optional"`
+		Hidden   json.RawMessage `json:"hidden"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var err error
+	if n.Required, n.requiredOrder, err = decodeOrderedObject(raw.Required); err != nil {
+		return fmt.Errorf("failed to decode required inputs: %w", err)
+	}
+	if n.Optional, n.optionalOrder, err = decodeOrderedObject(raw.Optional); err != nil {
+		return fmt.Errorf("failed to decode optional inputs: %w", err)
+	}
+	if n.Hidden, _, err = decodeOrderedObject(raw.Hidden); err != nil {
+		return fmt.Errorf("failed to decode hidden inputs: %w", err)
+	}
+	return nil
+}
+
+// decodeOrderedObject decodes a JSON object along with its key order.
+// A missing or null object decodes to nil.
+func decodeOrderedObject(data json.RawMessage) (map[string]interface{}, []string, error) {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil, nil
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil { // opening brace
+		return nil, nil, err
+	}
+	keys := make([]string, 0, len(values))
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, token.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, nil, err
+		}
+	}
+	return values, keys, nil
+}
+
+// InputSpec is a typed input definition from object_info, parsed from
+// ComfyUI's tuple format such as ["INT", {"default": 20, "min": 1}] or
+// [["a.safetensors", "b.safetensors"]]
+type InputSpec struct {
+	Name     string
+	Kind     string // INT, FLOAT, STRING, BOOLEAN, COMBO or a link type such as MODEL
+	Optional bool
+
+	Default    interface{}
+	Min        *float64
+	Max        *float64
+	Step       *float64
+	Round      *float64 // nil if unset or disabled with false
+	Multiline  bool
+	Options    []interface{} // allowed values of a COMBO input
+	Tooltip    string
+	ForceInput bool // shown as a link slot even though the kind has a widget
+	Lazy       bool // only evaluated if the node asks for it
+
+	// Config holds the raw options object, including options not modeled above
+	Config map[string]interface{}
+}
+
+// widgetKinds are the input kinds the UI shows as widgets
+var widgetKinds = map[string]bool{
+	"INT":     true,
+	"FLOAT":   true,
+	"STRING":  true,
+	"BOOLEAN": true,
+	"COMBO":   true,
+}
+
+// ParseInputSpec parses the definition of a single input as found in
+// NodeInputInfo. Hidden inputs, which are declared by a bare type name such
+// as "PROMPT", only get a Kind.
+func ParseInputSpec(name string, raw interface{}) InputSpec {
+	spec := InputSpec{Name: name}
+	if kind, ok := raw.(string); ok {
+		spec.Kind = kind
+		return spec
+	}
+	def, ok := raw.([]interface{})
+	if !ok || len(def) == 0 {
+		return spec
+	}
+
+	switch kind := def[0].(type) {
+	case string:
+		spec.Kind = kind
+	case []interface{}:
+		spec.Kind = "COMBO"
+		spec.Options = kind
+	}
+	if len(def) > 1 {
+		spec.Config, _ = def[1].(map[string]interface{})
+	}
+	if spec.Kind == "COMBO" && spec.Options == nil {
+		// Newer servers declare combos as ["COMBO", {"options": [...]}]
+		spec.Options, _ = spec.Config["options"].([]interface{})
+	}
+
+	spec.Default = spec.Config["default"]
+	spec.Min = spec.number("min")
+	spec.Max = spec.number("max")
+	spec.Step = spec.number("step")
+	spec.Round = spec.number("round")
+	spec.Multiline = spec.option("multiline")
+	spec.Tooltip, _ = spec.Config["tooltip"].(string)
+	spec.ForceInput = spec.option("forceInput")
+	spec.Lazy = spec.option("lazy")
+	return spec
+}
+
+// option returns a boolean option of the input
+func (s InputSpec) option(key string) bool {
+	v, _ := s.Config[key].(bool)
+	return v
+}
+
+// number returns a numeric option of the input, or nil if it is not a number
+func (s InputSpec) number(key string) *float64 {
+	n, ok := toFloat(s.Config[key])
+	if !ok {
+		return nil
+	}
+	return &n
+}
+
+// IsWidget reports whether the editor shows the input as a widget rather
+// than as a link slot
+func (s InputSpec) IsWidget() bool {
+	return widgetKinds[s.Kind] && !s.ForceInput
+}
+
+// ValidateValue checks a literal value against the input's kind, range,
+// integer step and allowed options. The error is a *ValidationError.
+func (s InputSpec) ValidateValue(value interface{}) error {
+	fail := func(typ, message, details string) error {
+		return &ValidationError{
+			Field:     s.Name,
+			Type:      typ,
+			Message:   message,
+			Details:   details,
+			ExtraInfo: map[string]interface{}{"input_name": s.Name, "received_value": value},
+		}
+	}
+
+	switch s.Kind {
+	case "INT", "FLOAT":
+		n, ok := toFloat(value)
+		if !ok || (s.Kind == "INT" && n != math.Trunc(n)) {
+			return fail("invalid_input_type", fmt.Sprintf("Failed to convert an input value to a %s value", s.Kind),
+				fmt.Sprintf("%s, %v", s.Name, value))
+		}
+		if s.Min != nil && n < *s.Min {
+			return fail("value_smaller_than_min", fmt.Sprintf("Value %v smaller than min of %v", value, *s.Min), s.Name)
+		}
+		if s.Max != nil && n > *s.Max {
+			return fail("value_bigger_than_max", fmt.Sprintf("Value %v bigger than max of %v", value, *s.Max), s.Name)
+		}
+		// ComfyUI does not enforce step, the editor snaps integer widgets to
+		// min + k*step and rounds floats, so only integers are checked
+		if s.Kind == "INT" && s.Step != nil && *s.Step > 0 {
+			offset := n
+			if s.Min != nil {
+				offset -= *s.Min
+			}
+			if math.Mod(offset, *s.Step) != 0 {
+				return fail("value_not_multiple_of_step", fmt.Sprintf("Value %v is not a multiple of step %v", value, *s.Step), s.Name)
+			}
+		}
+	case "STRING":
+		if _, ok := value.(string); !ok {
+			return fail("invalid_input_type", "Failed to convert an input value to a STRING value",
+				fmt.Sprintf("%s, %v", s.Name, value))
+		}
+	case "BOOLEAN":
+		if _, ok := value.(bool); !ok {
+			return fail("invalid_input_type", "Failed to convert an input value to a BOOLEAN value",
+				fmt.Sprintf("%s, %v", s.Name, value))
+		}
+	case "COMBO":
+		// An empty list means the options are only known at runtime
+		if len(s.Options) == 0 {
+			return nil
+		}
+		for _, option := range s.Options {
+			if sameValue(value, option) {
+				return nil
+			}
+		}
+		return fail("value_not_in_list", "Value not in list",
+			fmt.Sprintf("%s: '%v' not in %v", s.Name, value, s.Options))
+	default:
+		return fail("bad_linked_input", "Bad linked input, must be a length-2 list of [node_id, slot_index]", s.Name)
+	}
+	return nil
+}
+
+// widgetValueCount returns how many entries of widgets_values the input
+// occupies. Seeds carry a control_after_generate widget and file combos an
+// upload button, each storing an extra value after the input's own.
+func (s InputSpec) widgetValueCount() int {
+	switch {
+	case s.option("control_after_generate"):
+		return 2
+	case s.Kind == "INT" && (s.Name == "seed" || s.Name == "noise_seed") && s.Config["control_after_generate"] == nil:
+		return 2
+	case s.option("image_upload") || s.option("video_upload") || s.option("audio_upload"):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// widgetExtraValue returns the value stored for the extra widget counted by
+// widgetValueCount: a fixed seed, or the upload button's media kind
+func (s InputSpec) widgetExtraValue() interface{} {
+	switch {
+	case s.option("image_upload"):
+		return "image"
+	case s.option("video_upload"):
+		return "video"
+	case s.option("audio_upload"):
+		return "audio"
+	default:
+		return "fixed"
+	}
+}
+
+// defaultValue returns the input's declared default, falling back to the
+// first option of a combo or the zero value of its kind
+func (s InputSpec) defaultValue() interface{} {
+	if s.Default != nil {
+		return s.Default
+	}
+	switch s.Kind {
+	case "COMBO":
+		if len(s.Options) > 0 {
+			return s.Options[0]
+		}
+		return ""
+	case "INT", "FLOAT":
+		return 0
+	case "STRING":
+		return ""
+	case "BOOLEAN":
+		return false
+	}
+	return nil
+}
+
+// InputSpecs returns the class's required and then optional inputs in
+// declaration order. Hidden inputs, which the server fills in, are not
+// included.
+func (c NodeClassInfo) InputSpecs() []InputSpec {
+	var specs []InputSpec
+	add := func(inputs map[string]interface{}, order, declared []string, optional bool) {
+		if len(order) == 0 {
+			order = declared
+		}
+		seen := make(map[string]bool, len(inputs))
+		for _, name := range order {
+			if raw, ok := inputs[name]; ok && !seen[name] {
+				seen[name] = true
+				spec := ParseInputSpec(name, raw)
+				spec.Optional = optional
+				specs = append(specs, spec)
+			}
+		}
+		// Inputs missing from the order, e.g. for hand-built ObjectInfo
+		for _, name := range sortedKeys(inputs) {
+			if !seen[name] {
+				spec := ParseInputSpec(name, inputs[name])
+				spec.Optional = optional
+				specs = append(specs, spec)
+			}
+		}
+	}
+
+	add(c.Input.Required, c.InputOrder["required"], c.Input.requiredOrder, false)
+	add(c.Input.Optional, c.InputOrder["optional"], c.Input.optionalOrder, true)
+	return specs
+}
+
+// InputSpec returns the definition of the named required or optional input
+func (c NodeClassInfo) InputSpec(name string) (InputSpec, bool) {
+	if raw, ok := c.Input.Required[name]; ok {
+		return ParseInputSpec(name, raw), true
+	}
+	if raw, ok := c.Input.Optional[name]; ok {
+		spec := ParseInputSpec(name, raw)
+		spec.Optional = true
+		return spec, true
+	}
+	return InputSpec{}, false
+}
+
+// InputNames returns the names of the class's required and optional inputs
+// in declaration order
+func (c NodeClassInfo) InputNames() []string {
+	specs := c.InputSpecs()
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+	return names
+}
@@ -0,0 +1,120 @@
+package comfyui
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInputSpecs(t *testing.T) {
+	info := loadTestObjectInfo(t)
+
+	// Declared by input_order
+	sampler := info["KSampler"]
+	want := []string{"model", "seed", "steps", "cfg", "sampler_name", "scheduler", "positive", "negative", "latent_image", "denoise"}
+	if names := sampler.InputNames(); !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+
+	// Declared only by key order
+	if names := info["EmptyLatentImage"].InputNames(); !reflect.DeepEqual(names, []string{"width", "height", "batch_size"}) {
+		t.Errorf("Expected key order to be kept, got %v", names)
+	}
+
+	cfg, ok := sampler.InputSpec("cfg")
+	if !ok {
+		t.Fatal("Expected cfg input")
+	}
+	if cfg.Kind != "FLOAT" || cfg.Default != 8.0 || *cfg.Min != 0 || *cfg.Max != 100 || *cfg.Step != 0.1 || *cfg.Round != 0.01 {
+		t.Errorf("Unexpected cfg spec: %+v", cfg)
+	}
+	if !cfg.IsWidget() || cfg.Optional {
+		t.Errorf("Expected a required widget, got %+v", cfg)
+	}
+
+	model, _ := sampler.InputSpec("model")
+	if model.Kind != "MODEL" || model.IsWidget() || model.Min != nil {
+		t.Errorf("Unexpected model spec: %+v", model)
+	}
+
+	scheduler, _ := sampler.InputSpec("scheduler")
+	if scheduler.Kind != "COMBO" || !reflect.DeepEqual(scheduler.Options, []interface{}{"normal", "karras"}) {
+		t.Errorf("Unexpected scheduler spec: %+v", scheduler)
+	}
+
+	text, _ := info["CLIPTextEncode"].InputSpec("text")
+	if !text.Multiline || text.Config["dynamicPrompts"] != true {
+		t.Errorf("Unexpected text spec: %+v", text)
+	}
+
+	if _, ok := info["SaveImage"].InputSpec("prompt"); ok {
+		t.Error("Expected hidden inputs to be excluded")
+	}
+}
+
+func TestParseInputSpec(t *testing.T) {
+	var raw interface{}
+	json.Unmarshal([]byte(`["COMBO", {"options": ["a", "b"], "default": "b", "tooltip": "Pick one", "lazy": true}]`), &raw)
+	spec := ParseInputSpec("choice", raw)
+	if spec.Kind != "COMBO" || !reflect.DeepEqual(spec.Options, []interface{}{"a", "b"}) || spec.Default != "b" {
+		t.Errorf("Unexpected combo spec: %+v", spec)
+	}
+	if spec.Tooltip != "Pick one" || !spec.Lazy {
+		t.Errorf("Expected tooltip and lazy, got %+v", spec)
+	}
+
+	json.Unmarshal([]byte(`["INT", {"forceInput": true, "round": false}]`), &raw)
+	spec = ParseInputSpec("count", raw)
+	if !spec.ForceInput || spec.IsWidget() || spec.Round != nil {
+		t.Errorf("Unexpected forced input spec: %+v", spec)
+	}
+
+	if spec := ParseInputSpec("prompt", "PROMPT"); spec.Kind != "PROMPT" {
+		t.Errorf("Expected hidden input kind, got %+v", spec)
+	}
+}
+
+func TestInputSpecValidateValue(t *testing.T) {
+	info := loadTestObjectInfo(t)
+	width, _ := info["EmptyLatentImage"].InputSpec("width")
+	sampler, _ := info["KSampler"].InputSpec("sampler_name")
+	denoise, _ := info["KSampler"].InputSpec("denoise")
+	cfg, _ := info["KSampler"].InputSpec("cfg")
+	odd := ParseInputSpec("odd", []interface{}{"INT", map[string]interface{}{"min": 1.0, "step": 2.0}})
+
+	tests := []struct {
+		spec  InputSpec
+		value interface{}
+		typ   string
+	}{
+		{width, 512, ""},
+		{width, float64(1024), ""},
+		{width, 8, "value_smaller_than_min"},
+		{width, 20000, "value_bigger_than_max"},
+		{width, 100, "value_not_multiple_of_step"},
+		{width, "512", "invalid_input_type"},
+		{width, 512.5, "invalid_input_type"},
+		{denoise, 0.35, ""},
+		{denoise, 0.333, ""},
+		{cfg, 7.55, ""},
+		{odd, 3, ""},
+		{odd, 4, "value_not_multiple_of_step"},
+		{sampler, "euler", ""},
+		{sampler, "ddim", "value_not_in_list"},
+	}
+
+	for _, tt := range tests {
+		err := tt.spec.ValidateValue(tt.value)
+		if tt.typ == "" {
+			if err != nil {
+				t.Errorf("%s=%v: unexpected error %v", tt.spec.Name, tt.value, err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Type != tt.typ || verr.Field != tt.spec.Name {
+			t.Errorf("%s=%v: expected %s, got %v", tt.spec.Name, tt.value, tt.typ, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package comfyui
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// promptObserver records a prompt's lifecycle as a span, with a child span
+// per executed node, and reports it to the metrics collector. Events are read
+// from a separate subscription, which is only opened when the span is
+// recorded or metrics are collected, and completion uses the WebSocket.
+type promptObserver struct {
+	client   *Client
+	ctx      context.Context
+	span     trace.Span
+	workflow Workflow
+	name     string
+	start    time.Time
+	queuedAt time.Time // zero unless the prompt is queued while observed
+	sub      *Subscription
+	done     chan struct{}
+
+	// Owned by the run goroutine until done is closed
+	node      trace.Span
+	nodeID    string
+	nodeStart time.Time
+	cached    int
+}
+
+// observePrompt starts observing promptID. workflow may be nil, in which
+// case node class types and cache ratios are not recorded. queueing is set
+// when the caller is about to queue the prompt, to measure queue latency.
+func (c *Client) observePrompt(ctx context.Context, promptID, name string, workflow Workflow, queueing bool) (context.Context, *promptObserver) {
+	ctx, span := c.tracer.Start(ctx, "comfyui.prompt", trace.WithAttributes(
+		attribute.String("comfyui.prompt_id", promptID),
+		attribute.String("comfyui.client_id", c.GetClientID()),
+	))
+	if name != "" {
+		span.SetAttributes(attribute.String("comfyui.workflow", name))
+	}
+	if workflow != nil {
+		span.SetAttributes(attribute.Int("comfyui.node_count", len(workflow)))
+	}
+
+	o := &promptObserver{client: c, ctx: ctx, span: span, workflow: workflow, name: name, start: time.Now()}
+	if queueing {
+		o.queuedAt = o.start
+	}
+	if !span.IsRecording() && c.metrics == nil {
+		return ctx, o
+	}
+	if _, ok := c.completionStrategy().(WebSocketCompletion); !ok {
+		return ctx, o
+	}
+
+	sub, err := c.Subscribe(ctx, promptID)
+	if err != nil {
+		span.AddEvent("subscribe failed", trace.WithAttributes(attribute.String("error", err.Error())))
+		return ctx, o
+	}
+	o.sub = sub
+	o.done = make(chan struct{})
+	go o.run()
+	return ctx, o
+}
+
+// queued records that the prompt was accepted by the server
+func (o *promptObserver) queued(resp *QueuePromptResponse) {
+	if resp == nil {
+		return
+	}
+	o.span.AddEvent("queued", trace.WithAttributes(attribute.Int("comfyui.queue_number", resp.Number)))
+}
+
+// end stops observing, recording err on the prompt span
+func (o *promptObserver) end(err error) {
+	if o.sub != nil {
+		// Events already dispatched stay readable after Close
+		o.sub.Close()
+		<-o.done
+	}
+	o.endNode(nil)
+
+	if metrics := o.client.metrics; metrics != nil {
+		metrics.PromptExecuted(o.name, promptStatus(err), time.Since(o.start))
+		if o.workflow != nil && o.sub != nil && err == nil {
+			metrics.NodesCached(o.cached, len(o.workflow))
+		}
+	}
+
+	if err != nil {
+		o.span.RecordError(err)
+		o.span.SetStatus(codes.Error, err.Error())
+	}
+	o.span.End()
+}
+
+func (o *promptObserver) run() {
+	defer close(o.done)
+	for ev := range o.sub.Events() {
+		o.record(ev)
+	}
+}
+
+// record translates a prompt event into spans, span events and metrics
+func (o *promptObserver) record(ev Event) {
+	switch ev := ev.(type) {
+	case ExecutionStartEvent:
+		o.span.AddEvent("execution_start")
+		if o.client.metrics != nil && !o.queuedAt.IsZero() {
+			o.client.metrics.PromptQueueLatency(o.name, time.Since(o.queuedAt))
+		}
+	case ExecutionCachedEvent:
+		o.cached += len(ev.Nodes)
+		o.span.SetAttributes(attribute.Int("comfyui.cached_node_count", o.cached))
+		o.span.AddEvent("execution_cached", trace.WithAttributes(attribute.StringSlice("comfyui.cached_nodes", ev.Nodes)))
+	case ExecutingEvent:
+		o.endNode(nil)
+		if !ev.Done() {
+			o.startNode(*ev.Node)
+		}
+	case ProgressEvent:
+		if o.node != nil && ev.Node == o.nodeID {
+			o.node.AddEvent("progress", trace.WithAttributes(
+				attribute.Int("comfyui.progress.value", ev.Value),
+				attribute.Int("comfyui.progress.max", ev.Max),
+			))
+		}
+	case ExecutedEvent:
+		span := o.node
+		if span == nil || ev.Node != o.nodeID {
+			span = o.span
+		}
+		span.AddEvent("executed", trace.WithAttributes(
+			attribute.String("comfyui.node.id", ev.Node),
+			attribute.Int("comfyui.output.images", len(ev.Output.Images)),
+		))
+	case ExecutionErrorEvent:
+		if o.node != nil && ev.NodeID == o.nodeID {
+			o.endNode(newNodeError(&ev.ErrorData))
+		}
+		o.span.AddEvent("execution_error", trace.WithAttributes(attribute.String("comfyui.node.id", ev.NodeID)))
+	case ExecutionInterruptedEvent:
+		o.endNode(newInterruptedError(ev.PromptID, ev.NodeID, ev.NodeType))
+		o.span.AddEvent("execution_interrupted", trace.WithAttributes(attribute.String("comfyui.node.id", ev.NodeID)))
+	case ReconnectedEvent:
+		o.span.AddEvent("reconnected", trace.WithAttributes(attribute.Int("comfyui.reconnect.attempt", ev.Attempt)))
+	}
+}
+
+// startNode starts timing a node that began executing
+func (o *promptObserver) startNode(nodeID string) {
+	attrs := []attribute.KeyValue{attribute.String("comfyui.node.id", nodeID)}
+	if classType := o.classType(nodeID); classType != "" {
+		attrs = append(attrs, attribute.String("comfyui.node.class_type", classType))
+	}
+	_, o.node = o.client.tracer.Start(o.ctx, "comfyui.node "+nodeID, trace.WithAttributes(attrs...))
+	o.nodeID = nodeID
+	o.nodeStart = time.Now()
+}
+
+// endNode ends the current node, if any, recording err
+func (o *promptObserver) endNode(err error) {
+	if o.node == nil {
+		return
+	}
+	if err != nil {
+		o.node.RecordError(err)
+		o.node.SetStatus(codes.Error, err.Error())
+	} else if o.client.metrics != nil {
+		o.client.metrics.NodeExecuted(o.classType(o.nodeID), time.Since(o.nodeStart))
+	}
+	o.node.End()
+	o.node = nil
+	o.nodeID = ""
+}
+
+// classType returns the class type of nodeID, or "" if the workflow is unknown
+func (o *promptObserver) classType(nodeID string) string {
+	if node, ok := o.workflow[nodeID]; ok {
+		return node.ClassType
+	}
+	return ""
+}
+
+// promptStatus classifies the outcome of a prompt for metrics
+func promptStatus(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrInterrupted):
+		return "interrupted"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,185 @@
+package comfyui
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultTimeout is the HTTP timeout used when no client or timeout is given
+const defaultTimeout = 30 * time.Second
+
+// Option configures a Client created by NewClient
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	clientID   string
+	header     http.Header
+	retry      RetryPolicy
+	auth       []Authenticator
+	logger     *slog.Logger
+	dialer     *websocket.Dialer
+	tlsConfig  *tls.Config
+	completion CompletionStrategy
+	apiRoutes  bool
+	socketPath string // set for unix:// base URLs
+
+	tracerProvider trace.TracerProvider
+	metrics        MetricsCollector
+	limits         map[EndpointGroup]Limit
+
+	middleware           []Middleware
+	requestInterceptors  []RequestInterceptor
+	responseInterceptors []ResponseInterceptor
+	messageInterceptors  []MessageInterceptor
+}
+
+// WithHTTPClient sets the HTTP client used for API requests. Its transport's
+// proxy and TLS settings are also used for WebSocket connections unless
+// WithDialer is given.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *clientOptions) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the timeout of HTTP requests, 30s by default. It does
+// not modify a client passed to WithHTTPClient; a copy is used instead.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithClientID sets the client ID instead of generating a random one
+func WithClientID(clientID string) Option {
+	return func(o *clientOptions) {
+		o.clientID = clientID
+	}
+}
+
+// WithHeader adds a header sent with every HTTP request and the WebSocket handshake
+func WithHeader(key, value string) Option {
+	return func(o *clientOptions) {
+		o.header.Add(key, value)
+	}
+}
+
+// WithHeaders adds headers sent with every HTTP request and the WebSocket handshake
+func WithHeaders(header http.Header) Option {
+	return func(o *clientOptions) {
+		for key, values := range header {
+			for _, value := range values {
+				o.header.Add(key, value)
+			}
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) {
+		o.header.Set("User-Agent", userAgent)
+	}
+}
+
+// WithRetryPolicy sets the retry policy for HTTP requests, see SetRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *clientOptions) {
+		o.retry = policy
+	}
+}
+
+// WithLogger sets the logger, see SetLogger
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *clientOptions) {
+		o.logger = logger
+	}
+}
+
+// WithDialer sets the dialer used for WebSocket connections
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(o *clientOptions) {
+		o.dialer = dialer
+	}
+}
+
+// WithTLSConfig sets the TLS configuration for both HTTP requests and
+// WebSocket connections
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *clientOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithCompletionStrategy sets the completion strategy, see SetCompletionStrategy
+func WithCompletionStrategy(strategy CompletionStrategy) Option {
+	return func(o *clientOptions) {
+		o.completion = strategy
+	}
+}
+
+// buildHTTPClient returns the HTTP client described by the options, copying
+// a caller-supplied client before changing it
+func (o *clientOptions) buildHTTPClient() *http.Client {
+	var httpClient http.Client
+	if o.httpClient != nil {
+		if o.timeout <= 0 && o.tlsConfig == nil && o.socketPath == "" {
+			return o.httpClient
+		}
+		httpClient = *o.httpClient
+	} else {
+		httpClient.Timeout = defaultTimeout
+	}
+	if o.timeout > 0 {
+		httpClient.Timeout = o.timeout
+	}
+
+	if o.tlsConfig != nil || o.socketPath != "" {
+		transport, ok := httpClient.Transport.(*http.Transport)
+		if httpClient.Transport == nil {
+			transport, ok = http.DefaultTransport.(*http.Transport)
+		}
+		if ok {
+			transport = transport.Clone()
+			if o.tlsConfig != nil {
+				transport.TLSClientConfig = o.tlsConfig
+			}
+			if o.socketPath != "" {
+				transport.Proxy = nil
+				transport.DialContext = o.dialSocket
+			}
+			httpClient.Transport = transport
+		}
+	}
+	return &httpClient
+}
+
+// buildDialer returns the WebSocket dialer described by the options. Without
+// WithDialer the proxy and TLS settings of the HTTP transport are reused.
+func (o *clientOptions) buildDialer(httpClient *http.Client) *websocket.Dialer {
+	var dialer websocket.Dialer
+	if o.dialer != nil {
+		dialer = *o.dialer
+	} else {
+		dialer = *websocket.DefaultDialer
+		if transport, ok := httpClient.Transport.(*http.Transport); ok {
+			dialer.Proxy = transport.Proxy
+			dialer.TLSClientConfig = transport.TLSClientConfig
+		}
+	}
+	if o.tlsConfig != nil && dialer.TLSClientConfig == nil {
+		dialer.TLSClientConfig = o.tlsConfig
+	}
+	if o.socketPath != "" {
+		dialer.Proxy = nil
+		dialer.NetDialContext = o.dialSocket
+	}
+	return &dialer
+}
@@ -0,0 +1,145 @@
+package comfyui
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNewClientDefaults(t *testing.T) {
+	client := NewClient("http://localhost:8188/")
+	if client.baseURL != "http://localhost:8188" {
+		t.Errorf("Expected trailing slash to be trimmed, got %s", client.baseURL)
+	}
+	if client.httpClient.Timeout != defaultTimeout {
+		t.Errorf("Expected default timeout, got %v", client.httpClient.Timeout)
+	}
+	if client.GetClientID() == "" || client.retry.MaxAttempts != 0 {
+		t.Errorf("Unexpected defaults: %+v", client)
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	httpClient := &http.Client{Timeout: time.Minute}
+	client := NewClient("http://localhost:8188",
+		WithHTTPClient(httpClient),
+		WithTimeout(5*time.Second),
+		WithClientID("my-client"),
+		WithRetryPolicy(DefaultRetryPolicy()),
+		WithCompletionStrategy(PollingCompletion{}),
+	)
+
+	if client.httpClient.Timeout != 5*time.Second {
+		t.Errorf("Expected timeout 5s, got %v", client.httpClient.Timeout)
+	}
+	if httpClient.Timeout != time.Minute {
+		t.Error("Expected the caller's HTTP client not to be modified")
+	}
+	if client.GetClientID() != "my-client" {
+		t.Errorf("Expected client ID my-client, got %s", client.GetClientID())
+	}
+	if client.retry.MaxAttempts != 3 {
+		t.Errorf("Expected retry policy to be set, got %+v", client.retry)
+	}
+	if _, ok := client.completionStrategy().(PollingCompletion); !ok {
+		t.Errorf("Expected polling completion, got %T", client.completionStrategy())
+	}
+
+	if c := NewClientWithHTTPClient("http://localhost:8188", httpClient); c.httpClient != httpClient {
+		t.Error("Expected NewClientWithHTTPClient to use the given client")
+	}
+}
+
+func TestHeadersAppliedToHTTPAndWebSocket(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]http.Header)
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+
+		if r.URL.Path == "/ws" {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL,
+		WithUserAgent("sdk-test/1.0"),
+		WithHeader("X-Team", "render"),
+		WithHeaders(http.Header{"X-Trace": {"abc"}}),
+	)
+
+	if _, err := client.GetHistory(context.Background(), ""); err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	ws, err := client.ConnectWebSocket(context.Background())
+	if err != nil {
+		t.Fatalf("ConnectWebSocket failed: %v", err)
+	}
+	ws.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/history", "/ws"} {
+		header := seen[path]
+		if header.Get("User-Agent") != "sdk-test/1.0" || header.Get("X-Team") != "render" || header.Get("X-Trace") != "abc" {
+			t.Errorf("%s: missing default headers, got %v", path, header)
+		}
+	}
+}
+
+func TestWithDialer(t *testing.T) {
+	server, _ := newTestWebSocketServer(t, func(conn *websocket.Conn, n int) {})
+
+	dialed := false
+	dialer := &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = true
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+
+	ws, err := NewClient(server.URL, WithDialer(dialer)).ConnectWebSocket(context.Background())
+	if err != nil {
+		t.Fatalf("ConnectWebSocket failed: %v", err)
+	}
+	ws.Close()
+	if !dialed {
+		t.Error("Expected the custom dialer to be used")
+	}
+}
+
+func TestWithTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	// The test server's certificate is not trusted by default
+	if _, err := NewClient(server.URL).GetHistory(context.Background(), ""); err == nil {
+		t.Fatal("Expected certificate verification to fail")
+	}
+
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+	client := NewClient(server.URL, WithTLSConfig(tlsConfig))
+	if _, err := client.GetHistory(context.Background(), ""); err != nil {
+		t.Errorf("Expected request with TLS config to succeed, got %v", err)
+	}
+	if client.dialer.TLSClientConfig != tlsConfig {
+		t.Error("Expected TLS config to be used for WebSocket connections")
+	}
+}
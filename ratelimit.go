@@ -0,0 +1,186 @@
+package comfyui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EndpointGroup groups endpoints that share a rate limit
+type EndpointGroup string
+
+const (
+	EndpointSubmit   EndpointGroup = "submit"   // POST /prompt
+	EndpointQuery    EndpointGroup = "query"    // queue, history, system info and other API calls
+	EndpointDownload EndpointGroup = "download" // GET /view
+	EndpointUpload   EndpointGroup = "upload"   // /upload/*
+)
+
+// Limit configures client-side limits for an endpoint group. Zero values
+// leave the corresponding limit disabled.
+type Limit struct {
+	Rate        float64 // sustained requests per second
+	Burst       int     // requests allowed at once above the rate, defaults to 1
+	MaxInFlight int     // maximum concurrent requests, including reading the response body
+}
+
+// WithLimit sets the rate and concurrency limit for an endpoint group.
+// Requests wait for capacity until their context is done.
+func WithLimit(group EndpointGroup, limit Limit) Option {
+	return func(o *clientOptions) {
+		if o.limits == nil {
+			o.limits = make(map[EndpointGroup]Limit)
+		}
+		o.limits[group] = limit
+	}
+}
+
+// endpointGroup classifies a request by method and endpoint label
+func endpointGroup(method, endpoint string) EndpointGroup {
+	switch {
+	case endpoint == "/prompt" && method == http.MethodPost:
+		return EndpointSubmit
+	case endpoint == "/view":
+		return EndpointDownload
+	case endpoint == "/upload":
+		return EndpointUpload
+	default:
+		return EndpointQuery
+	}
+}
+
+// buildLimiters creates a limiter for each configured group
+func (o *clientOptions) buildLimiters() map[EndpointGroup]*limiter {
+	if len(o.limits) == 0 {
+		return nil
+	}
+	limiters := make(map[EndpointGroup]*limiter, len(o.limits))
+	for group, limit := range o.limits {
+		limiters[group] = newLimiter(limit)
+	}
+	return limiters
+}
+
+// limiter combines a token bucket with a cap on requests in flight
+type limiter struct {
+	bucket   *tokenBucket
+	inFlight chan struct{}
+}
+
+func newLimiter(limit Limit) *limiter {
+	l := &limiter{}
+	if limit.Rate > 0 {
+		l.bucket = newTokenBucket(limit.Rate, limit.Burst)
+	}
+	if limit.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, limit.MaxInFlight)
+	}
+	return l
+}
+
+// acquire waits for a token and an in-flight slot. The returned function
+// releases the slot and must be called once the request is done.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if l.inFlight != nil {
+			<-l.inFlight
+		}
+	}
+
+	if l.bucket != nil {
+		if err := l.bucket.wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// tokenBucket is a token bucket rate limiter. Callers reserve a token and
+// sleep until it becomes available, so waiting requests are served in order.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64 // negative when tokens are reserved by waiting callers
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait takes a token, waiting until one is available or ctx is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the reservation back
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// limit waits for capacity in the request's endpoint group. The returned
+// function releases it and is safe to call more than once.
+func (c *Client) limit(req *http.Request) (func(), error) {
+	l, ok := c.limiters[endpointGroup(req.Method, endpointLabel(c.endpointPath(req.URL.Path)))]
+	if !ok {
+		return func() {}, nil
+	}
+
+	release, err := l.acquire(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for rate limit: %w", err)
+	}
+	var once sync.Once
+	return func() { once.Do(release) }, nil
+}
+
+// releaseBody releases a limiter slot when the response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,126 @@
+package comfyui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEndpointGroup(t *testing.T) {
+	tests := []struct {
+		method, endpoint string
+		want             EndpointGroup
+	}{
+		{"POST", "/prompt", EndpointSubmit},
+		{"GET", "/prompt", EndpointQuery},
+		{"GET", "/view", EndpointDownload},
+		{"POST", "/upload", EndpointUpload},
+		{"GET", "/history", EndpointQuery},
+		{"POST", "/queue", EndpointQuery},
+	}
+	for _, tt := range tests {
+		if got := endpointGroup(tt.method, tt.endpoint); got != tt.want {
+			t.Errorf("endpointGroup(%s, %s) = %s, want %s", tt.method, tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(50, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := bucket.wait(ctx); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+	}
+	// Two tokens are available at once, the other two take 20ms each
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected rate limiting to delay requests, took %v", elapsed)
+	}
+
+	slow := newTokenBucket(0.1, 1)
+	slow.wait(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := slow.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected wait to stop at the deadline, got %v", err)
+	}
+	if slow.tokens < -0.01 {
+		t.Errorf("Expected the reservation to be returned, tokens = %v", slow.tokens)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithLimit(EndpointDownload, Limit{MaxInFlight: 2}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetImage(context.Background(), "a.png", "", "output"); err != nil {
+				t.Errorf("GetImage failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p := atomic.LoadInt32(&peak); p != 2 {
+		t.Errorf("Expected at most 2 requests in flight, peak was %d", p)
+	}
+	// Other groups are not limited
+	if _, ok := client.limiters[EndpointQuery]; ok {
+		t.Error("Expected no limiter for the query group")
+	}
+}
+
+func TestRateLimitHonorsContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"prompt_id": "p", "number": 1}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithLimit(EndpointSubmit, Limit{Rate: 0.1}))
+	if _, err := client.QueuePrompt(context.Background(), Workflow{}, nil); err != nil {
+		t.Fatalf("First request should not wait: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.QueuePrompt(ctx, Workflow{}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected waiting to stop with the context, took %v", elapsed)
+	}
+
+	// Queries are not affected by the submit limit
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.GetQueue(ctx); err != nil {
+		t.Errorf("Expected query to bypass the submit limit, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package comfyui
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how failed HTTP requests are retried.
+// Only idempotent requests are retried: GETs, queue and history management,
+// uploads with Overwrite, and POST /prompt when the prompt ID is supplied
+// by the client (QueuePromptWithID, Run).
+type RetryPolicy struct {
+	MaxAttempts          int // total attempts including the first; <= 1 disables retries
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	Multiplier           float64
+	Jitter               float64 // fraction of each backoff that is randomized, 0 to 1
+	RetryableStatusCodes []int   // defaults to 408, 429, 500, 502, 503 and 504
+}
+
+// DefaultRetryPolicy returns a policy making up to 3 attempts,
+// backing off from 500ms up to 10s with 20% jitter
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// defaultRetryableStatusCodes are retried when RetryableStatusCodes is nil
+var defaultRetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// SetRetryPolicy sets the retry policy for HTTP requests. By default
+// requests are not retried.
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// shouldRetry reports whether a failed attempt may be retried
+func (p RetryPolicy) shouldRetry(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		codes := p.RetryableStatusCodes
+		if codes == nil {
+			codes = defaultRetryableStatusCodes
+		}
+		for _, code := range codes {
+			if apiErr.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	return errors.Is(err, ErrConnectionFailed) || errors.Is(err, ErrTimeout)
+}
+
+// backoff returns the delay before the given (1-based) retry, honoring the
+// server's Retry-After when it asks for a longer wait
+func (p RetryPolicy) backoff(retry int, err error) time.Duration {
+	delay := exponentialBackoff(p.InitialBackoff, p.MaxBackoff, p.Multiplier, retry)
+
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		// Spread the delay over [delay*(1-jitter), delay]
+		delay -= time.Duration(rand.Float64() * jitter * float64(delay))
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+		delay = apiErr.RetryAfter
+	}
+	return delay
+}
+
+// exponentialBackoff returns the delay before the given (1-based) attempt,
+// growing from initial by multiplier up to maxDelay. initial defaults to
+// 500ms and multiplier to 2; a maxDelay <= 0 means no limit.
+func exponentialBackoff(initial, maxDelay time.Duration, multiplier float64, attempt int) time.Duration {
+	delay := initial
+	if delay <= 0 {
+		delay = 500 * time.Millisecond
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	for i := 1; i < attempt; i++ {
+		delay = time.Duration(float64(delay) * multiplier)
+		if maxDelay > 0 && delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
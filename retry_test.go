@@ -0,0 +1,226 @@
+package comfyui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"queue_running": [], "queue_pending": []}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	if _, err := client.GetQueue(context.Background()); err != nil {
+		t.Fatalf("Expected retries to succeed, got %v", err)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var attempts int32
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	_, err := client.GetQueue(context.Background())
+	if !errors.Is(err, ErrConnectionFailed) {
+		t.Errorf("Expected last error to be returned, got %v", err)
+	}
+	if n := atomic.SwapInt32(&attempts, 0); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+
+	// Client errors are not retried
+	status = http.StatusNotFound
+	client.GetQueue(context.Background())
+	if n := atomic.SwapInt32(&attempts, 0); n != 1 {
+		t.Errorf("Expected 404 not to be retried, got %d attempts", n)
+	}
+
+	// Without a policy nothing is retried
+	status = http.StatusServiceUnavailable
+	NewClient(server.URL).GetQueue(context.Background())
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("Expected a single attempt by default, got %d", n)
+	}
+}
+
+func TestRetryQueuePromptOnlyWithID(t *testing.T) {
+	var attempts int32
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Lookups made before a replay find nothing
+		switch r.URL.Path {
+		case "/queue":
+			w.Write([]byte(`{"queue_running": [], "queue_pending": []}`))
+			return
+		case "/history/fixed-id":
+			w.Write([]byte(`{}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if atomic.AddInt32(&attempts, 1)%2 == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"prompt_id": "fixed-id", "number": 1}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetRetryPolicy(testRetryPolicy())
+	workflow := Workflow{"9": Node{ClassType: "SaveImage"}}
+
+	if _, err := client.QueuePrompt(context.Background(), workflow, nil); err == nil {
+		t.Error("Expected QueuePrompt without an ID not to be retried")
+	}
+	if n := atomic.SwapInt32(&attempts, 0); n != 1 {
+		t.Errorf("Expected 1 attempt, got %d", n)
+	}
+
+	bodies = nil
+	resp, err := client.QueuePromptWithID(context.Background(), "fixed-id", workflow, nil)
+	if err != nil {
+		t.Fatalf("Expected QueuePromptWithID to be retried, got %v", err)
+	}
+	if resp.PromptID != "fixed-id" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if len(bodies) != 2 || bodies[0] != bodies[1] || bodies[1] == "" {
+		t.Errorf("Expected the request body to be replayed, got %q", bodies)
+	}
+}
+
+func TestRetryQueuePromptSkipsReplayOfArrivedPrompt(t *testing.T) {
+	var posts int32
+	var queued atomic.Value
+	queued.Store("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prompt":
+			atomic.AddInt32(&posts, 1)
+			var req QueuePromptRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			queued.Store(req.PromptID)
+
+			// The prompt is accepted but the response is lost
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Failed to hijack: %v", err)
+				return
+			}
+			conn.Close()
+		case "/queue":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"queue_running": []interface{}{},
+				"queue_pending": []interface{}{[]interface{}{1, queued.Load(), map[string]interface{}{}, map[string]interface{}{}, []interface{}{"9"}}},
+			})
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	resp, err := client.QueuePromptWithID(context.Background(), "fixed-id", Workflow{"9": Node{ClassType: "SaveImage"}}, nil)
+	if err != nil {
+		t.Fatalf("Expected the arrived prompt to count as queued, got %v", err)
+	}
+	if resp.PromptID != "fixed-id" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if n := atomic.LoadInt32(&posts); n != 1 {
+		t.Errorf("Expected the prompt to be posted once, got %d", n)
+	}
+}
+
+func TestRetryHonorsContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.GetQueue(ctx)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Minute {
+		t.Errorf("Expected *APIError with Retry-After, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected cancellation to stop the backoff, took %v", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("Expected 3s, got %v", d)
+	}
+	if d := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); d < 59*time.Minute {
+		t.Errorf("Expected about an hour, got %v", d)
+	}
+	for _, value := range []string{"", "soon", "-1", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)} {
+		if d := parseRetryAfter(value); d != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, want 0", value, d)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, want := range expected {
+		if got := policy.backoff(i+1, nil); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		if got := policy.backoff(1, nil); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("Jittered backoff %v out of range", got)
+		}
+	}
+
+	retryAfter := &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}
+	if got := policy.backoff(1, retryAfter); got != 5*time.Second {
+		t.Errorf("Expected Retry-After to take precedence, got %v", got)
+	}
+}
@@ -0,0 +1,70 @@
+package comfyui
+
+import (
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the SDK's spans
+const tracerName = "github.com/yourusername/comfyui-go-sdk"
+
+// WithTracerProvider sets the OpenTelemetry tracer provider. By default the
+// global provider is used, which does nothing unless one is installed.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *clientOptions) {
+		o.tracerProvider = provider
+	}
+}
+
+// buildTracer returns the tracer described by the options
+func (o *clientOptions) buildTracer() trace.Tracer {
+	provider := o.tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// startRequestSpan starts a client span for a REST call and injects the
+// trace context into the request headers
+func (c *Client) startRequestSpan(req *http.Request) (*http.Request, trace.Span) {
+	path := c.endpointPath(req.URL.Path)
+	ctx, span := c.tracer.Start(req.Context(), req.Method+" "+path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.path", path),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("comfyui.client_id", c.GetClientID()),
+		),
+	)
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, span
+}
+
+// endRequestSpan records the outcome of a REST call and ends its span
+func endRequestSpan(span trace.Span, resp *http.Response, err error, attempts int) {
+	if attempts > 1 {
+		span.SetAttributes(attribute.Int("http.request.resend_count", attempts-1))
+	}
+
+	var apiErr *APIError
+	switch {
+	case resp != nil:
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	case errors.As(err, &apiErr):
+		span.SetAttributes(attribute.Int("http.response.status_code", apiErr.StatusCode))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,176 @@
+package comfyui
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestTracer() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+// spanAttr returns the value of key on span, or an invalid value
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func findSpan(spans tracetest.SpanStubs, name string) (tracetest.SpanStub, bool) {
+	for _, span := range spans {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+func TestRunTracesPromptLifecycle(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.onPrompt = func(req QueuePromptRequest) {
+		pid := req.PromptID
+		server.setHistory(pid, successHistory(pid))
+		server.send("execution_start", map[string]interface{}{"prompt_id": pid})
+		server.send("execution_cached", map[string]interface{}{"prompt_id": pid, "nodes": []string{"4"}})
+		server.send("executing", map[string]interface{}{"prompt_id": pid, "node": "3"})
+		server.send("progress", map[string]interface{}{"prompt_id": pid, "node": "3", "value": 1, "max": 2})
+		server.send("progress", map[string]interface{}{"prompt_id": pid, "node": "3", "value": 2, "max": 2})
+		server.send("executing", map[string]interface{}{"prompt_id": pid, "node": "9"})
+		server.send("executed", map[string]interface{}{"prompt_id": pid, "node": "9", "output": map[string]interface{}{}})
+		server.send("executing", map[string]interface{}{"prompt_id": pid, "node": nil})
+	}
+
+	provider, exporter := newTestTracer()
+	client := NewClient(server.URL, WithTracerProvider(provider))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	workflow := Workflow{
+		"3": Node{ClassType: "KSampler"},
+		"4": Node{ClassType: "CheckpointLoaderSimple"},
+		"9": Node{ClassType: "SaveImage"},
+	}
+	if _, err := client.Run(ctx, workflow, RunOptions{PromptID: "pid"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	prompt, ok := findSpan(spans, "comfyui.prompt")
+	if !ok {
+		t.Fatalf("No prompt span in %d spans", len(spans))
+	}
+	if spanAttr(prompt, "comfyui.prompt_id").AsString() != "pid" || spanAttr(prompt, "comfyui.cached_node_count").AsInt64() != 1 {
+		t.Errorf("Unexpected prompt span attributes: %v", prompt.Attributes)
+	}
+	var events []string
+	for _, ev := range prompt.Events {
+		events = append(events, ev.Name)
+	}
+	for _, name := range []string{"queued", "execution_start", "execution_cached"} {
+		if !containsString(events, name) {
+			t.Errorf("Expected prompt span event %s, got %v", name, events)
+		}
+	}
+
+	sampler, ok := findSpan(spans, "comfyui.node 3")
+	if !ok {
+		t.Fatal("No span for node 3")
+	}
+	if spanAttr(sampler, "comfyui.node.class_type").AsString() != "KSampler" {
+		t.Errorf("Unexpected node attributes: %v", sampler.Attributes)
+	}
+	if len(sampler.Events) != 2 || sampler.Events[0].Name != "progress" {
+		t.Errorf("Expected 2 progress events, got %v", sampler.Events)
+	}
+	if sampler.Parent.SpanID() != prompt.SpanContext.SpanID() {
+		t.Error("Expected node span to be a child of the prompt span")
+	}
+
+	save, ok := findSpan(spans, "comfyui.node 9")
+	if !ok || len(save.Events) != 1 || save.Events[0].Name != "executed" {
+		t.Errorf("Unexpected span for node 9: %+v", save)
+	}
+
+	queue, ok := findSpan(spans, "POST /prompt")
+	if !ok {
+		t.Fatal("No span for POST /prompt")
+	}
+	if queue.Parent.SpanID() != prompt.SpanContext.SpanID() || spanAttr(queue, "http.response.status_code").AsInt64() != 200 {
+		t.Errorf("Unexpected REST span: %+v", queue)
+	}
+}
+
+func TestRunTracesNodeError(t *testing.T) {
+	server := newFakeComfyUI(t)
+	server.onPrompt = func(req QueuePromptRequest) {
+		pid := req.PromptID
+		server.setHistory(pid, errorHistory(pid))
+		server.send("executing", map[string]interface{}{"prompt_id": pid, "node": "3"})
+		server.send("execution_error", map[string]interface{}{
+			"prompt_id": pid, "node_id": "3", "node_type": "KSampler",
+			"exception_type": "RuntimeError", "exception_message": "boom",
+		})
+		server.send("executing", map[string]interface{}{"prompt_id": pid, "node": nil})
+	}
+
+	provider, exporter := newTestTracer()
+	client := NewClient(server.URL, WithTracerProvider(provider))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Run(ctx, Workflow{"3": Node{ClassType: "KSampler"}}, RunOptions{}); err == nil {
+		t.Fatal("Expected Run to fail")
+	}
+
+	spans := exporter.GetSpans()
+	for _, name := range []string{"comfyui.prompt", "comfyui.node 3"} {
+		span, ok := findSpan(spans, name)
+		if !ok || span.Status.Code != codes.Error {
+			t.Errorf("Expected %s to have error status, got %+v", name, span.Status)
+		}
+	}
+}
+
+func TestRequestSpan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	provider, exporter := newTestTracer()
+	client := NewClient(server.URL, WithTracerProvider(provider))
+	client.GetQueue(context.Background())
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /queue" || span.Status.Code != codes.Error || spanAttr(span, "http.response.status_code").AsInt64() != 404 {
+		t.Errorf("Unexpected span: %+v", span)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
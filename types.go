@@ -14,6 +14,135 @@ type Workflow map[string]Node
 type Node struct {
 	ClassType string                 `json:"class_type"`
 	Inputs    map[string]interface{} `json:"inputs"`
+	Meta      *NodeMeta              `json:"_meta,omitempty"`
+	// Extra holds fields the SDK does not model, kept on round trips
+	Extra map[string]interface{} `json:"-"`
+}
+
+// NodeMeta is the editor metadata of a node, stored under _meta
+type NodeMeta struct {
+	Title string `json:"title,omitempty"`
+	// Extra holds metadata fields other than title
+	Extra map[string]interface{} `json:"-"`
+}
+
+// Title returns the node's title from its metadata, or "" if it has none
+func (n Node) Title() string {
+	if n.Meta == nil {
+		return ""
+	}
+	return n.Meta.Title
+}
+
+// UnmarshalJSON implements custom JSON unmarshaling for Node
+func (n *Node) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*n = Node{}
+	for key, value := range raw {
+		var err error
+		switch key {
+		case "class_type":
+			err = json.Unmarshal(value, &n.ClassType)
+		case "inputs":
+			err = json.Unmarshal(value, &n.Inputs)
+		case "_meta":
+			err = json.Unmarshal(value, &n.Meta)
+		default:
+			var v interface{}
+			if err = json.Unmarshal(value, &v); err == nil {
+				if n.Extra == nil {
+					n.Extra = make(map[string]interface{})
+				}
+				n.Extra[key] = v
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode node field %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON implements custom JSON marshaling for Node
+func (n Node) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(n.Extra)+3)
+	for key, value := range n.Extra {
+		out[key] = value
+	}
+	out["class_type"] = n.ClassType
+	out["inputs"] = n.Inputs
+	if n.Meta != nil {
+		out["_meta"] = n.Meta
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements custom JSON unmarshaling for NodeMeta
+func (m *NodeMeta) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = nodeMetaFromMap(raw)
+	return nil
+}
+
+// MarshalJSON implements custom JSON marshaling for NodeMeta
+func (m NodeMeta) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(m.Extra)+1)
+	for key, value := range m.Extra {
+		out[key] = value
+	}
+	if m.Title != "" {
+		out["title"] = m.Title
+	}
+	return json.Marshal(out)
+}
+
+// nodeFromMap builds a node from its decoded JSON object, ignoring fields
+// of the wrong type
+func nodeFromMap(data map[string]interface{}) Node {
+	node := Node{}
+	for key, value := range data {
+		switch key {
+		case "class_type":
+			node.ClassType, _ = value.(string)
+		case "inputs":
+			node.Inputs, _ = value.(map[string]interface{})
+		case "_meta":
+			if meta, ok := value.(map[string]interface{}); ok {
+				m := nodeMetaFromMap(meta)
+				node.Meta = &m
+			}
+		default:
+			if node.Extra == nil {
+				node.Extra = make(map[string]interface{})
+			}
+			node.Extra[key] = value
+		}
+	}
+	return node
+}
+
+// nodeMetaFromMap builds node metadata from its decoded JSON object
+func nodeMetaFromMap(data map[string]interface{}) NodeMeta {
+	meta := NodeMeta{}
+	for key, value := range data {
+		if title, ok := value.(string); ok && key == "title" {
+			meta.Title = title
+			continue
+		}
+		if meta.Extra == nil {
+			meta.Extra = make(map[string]interface{})
+		}
+		meta.Extra[key] = value
+	}
+	return meta
 }
 
 // QueuePromptRequest represents the request to queue a prompt
@@ -106,14 +235,7 @@ func parseQueueItemArray(arr []interface{}) (QueueItem, error) {
 		workflow := make(Workflow)
 		for k, v := range promptMap {
 			if nodeMap, ok := v.(map[string]interface{}); ok {
-				node := Node{}
-				if classType, ok := nodeMap["class_type"].(string); ok {
-					node.ClassType = classType
-				}
-				if inputs, ok := nodeMap["inputs"].(map[string]interface{}); ok {
-					node.Inputs = inputs
-				}
-				workflow[k] = node
+				workflow[k] = nodeFromMap(nodeMap)
 			}
 		}
 		item.Prompt = workflow
@@ -183,14 +305,7 @@ func (p *PromptArray) UnmarshalJSON(data []byte) error {
 		workflow := make(Workflow)
 		for k, v := range workflowData {
 			if nodeData, ok := v.(map[string]interface{}); ok {
-				node := Node{}
-				if classType, ok := nodeData["class_type"].(string); ok {
-					node.ClassType = classType
-				}
-				if inputs, ok := nodeData["inputs"].(map[string]interface{}); ok {
-					node.Inputs = inputs
-				}
-				workflow[k] = node
+				workflow[k] = nodeFromMap(nodeData)
 			}
 		}
 		p.Workflow = workflow
@@ -242,18 +357,157 @@ type HistoryStatus struct {
 	Messages  []interface{} `json:"messages"`
 }
 
-// NodeOutput represents the output of a node
+// ExecutionError returns the failure recorded in the status messages:
+// a *NodeError for an execution_error, an error wrapping ErrInterrupted for
+// an execution_interrupted, ErrExecutionFailed for any other failed status,
+// and nil for a successful prompt.
+func (s HistoryStatus) ExecutionError() error {
+	for _, m := range s.Messages {
+		entry, ok := m.([]interface{})
+		if !ok || len(entry) < 2 {
+			continue
+		}
+		msgType, _ := entry[0].(string)
+		data, _ := json.Marshal(entry[1])
+
+		switch MessageType(msgType) {
+		case MessageTypeError:
+			var errData ErrorData
+			if err := json.Unmarshal(data, &errData); err == nil {
+				return newNodeError(&errData)
+			}
+		case MessageTypeInterrupted:
+			var ev ExecutionInterruptedEvent
+			if err := json.Unmarshal(data, &ev); err == nil {
+				return newInterruptedError(ev.PromptID, ev.NodeID, ev.NodeType)
+			}
+		}
+	}
+
+	if s.StatusStr == "error" {
+		return ErrExecutionFailed
+	}
+	return nil
+}
+
+// NodeOutput represents the output of a node.
+// Images and Text are decoded into typed fields; every other key
+// (gifs, audio, video, animated, latents, meshes, custom node outputs)
+// is kept as-is in Data.
 type NodeOutput struct {
 	Images []ImageInfo            `json:"images,omitempty"`
 	Text   []string               `json:"text,omitempty"`
 	Data   map[string]interface{} `json:"-"`
 }
 
-// ImageInfo represents information about an output image
+// UnmarshalJSON implements custom JSON unmarshaling for NodeOutput
+func (o *NodeOutput) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*o = NodeOutput{}
+	for key, value := range raw {
+		// Custom nodes may reuse the typed keys with other shapes;
+		// keep those in Data rather than failing the whole history
+		switch key {
+		case "images":
+			if err := json.Unmarshal(value, &o.Images); err == nil {
+				continue
+			}
+			o.Images = nil
+		case "text":
+			if err := json.Unmarshal(value, &o.Text); err == nil {
+				continue
+			}
+			o.Text = nil
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(value, &v); err != nil {
+			return fmt.Errorf("failed to decode output %s: %w", key, err)
+		}
+		if o.Data == nil {
+			o.Data = make(map[string]interface{})
+		}
+		o.Data[key] = v
+	}
+
+	return nil
+}
+
+// MarshalJSON implements custom JSON marshaling for NodeOutput
+func (o NodeOutput) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(o.Data)+2)
+	for key, value := range o.Data {
+		out[key] = value
+	}
+	if len(o.Images) > 0 {
+		out["images"] = o.Images
+	}
+	if len(o.Text) > 0 {
+		out["text"] = o.Text
+	}
+	return json.Marshal(out)
+}
+
+// Files decodes a list of output files stored under key in Data
+func (o NodeOutput) Files(key string) []ImageInfo {
+	value, ok := o.Data[key]
+	if !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var files []ImageInfo
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil
+	}
+	return files
+}
+
+// Gifs returns animated outputs, e.g. from VideoHelperSuite's combine node
+func (o NodeOutput) Gifs() []ImageInfo {
+	return o.Files("gifs")
+}
+
+// Audio returns audio file outputs, e.g. from SaveAudio
+func (o NodeOutput) Audio() []ImageInfo {
+	return o.Files("audio")
+}
+
+// Video returns video file outputs
+func (o NodeOutput) Video() []ImageInfo {
+	return o.Files("video")
+}
+
+// Animated reports, per entry of Images, whether the image is animated
+// (e.g. WEBP or video output from SaveAnimatedWEBP / SaveVideo)
+func (o NodeOutput) Animated() []bool {
+	values, ok := o.Data["animated"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	animated := make([]bool, 0, len(values))
+	for _, v := range values {
+		b, _ := v.(bool)
+		animated = append(animated, b)
+	}
+	return animated
+}
+
+// ImageInfo represents information about an output image or other media file
 type ImageInfo struct {
-	Filename  string `json:"filename"`
-	Subfolder string `json:"subfolder"`
-	Type      string `json:"type"`
+	Filename  string  `json:"filename"`
+	Subfolder string  `json:"subfolder"`
+	Type      string  `json:"type"`
+	Format    string  `json:"format,omitempty"`     // MIME type of video outputs, e.g. "video/h264-mp4"
+	FrameRate float64 `json:"frame_rate,omitempty"` // frames per second of video outputs
 }
 
 // SystemStats represents system statistics
@@ -293,13 +547,21 @@ type NodeClassInfo struct {
 	Description string        `json:"description"`
 	Category    string        `json:"category"`
 	OutputNode  bool          `json:"output_node"`
+	// InputOrder lists input names in declaration order, keyed by
+	// required/optional/hidden. Reported by newer ComfyUI versions.
+	InputOrder map[string][]string `json:"input_order,omitempty"`
 }
 
-// NodeInputInfo represents input information for a node
+// NodeInputInfo represents input information for a node. The values are
+// raw [type, {options}] tuples; NodeClassInfo.InputSpecs parses them.
 type NodeInputInfo struct {
 	Required map[string]interface{} `json:"required"`
 	Optional map[string]interface{} `json:"optional,omitempty"`
 	Hidden   map[string]interface{} `json:"hidden,omitempty"`
+
+	// Key order of Required and Optional as received from the server
+	requiredOrder []string
+	optionalOrder []string
 }
 
 // UploadImageResponse represents the response from uploading an image
@@ -320,18 +582,40 @@ type UploadOptions struct {
 type WebSocketMessage struct {
 	Type string                 `json:"type"`
 	Data map[string]interface{} `json:"data"`
+
+	// Event is the typed form of the message, decoded once by the read loop
+	Event Event `json:"-"`
+}
+
+// PreviewImage represents a live preview frame sent as a binary WebSocket message.
+// PromptID and NodeID come from the frame metadata when ComfyUI provides it,
+// otherwise from the most recent executing message.
+type PreviewImage struct {
+	Format        string // "JPEG", "PNG" or "WEBP"
+	MimeType      string
+	Data          []byte
+	PromptID      string
+	NodeID        string
+	DisplayNodeID string
 }
 
 // MessageType represents the type of WebSocket message
 type MessageType string
 
 const (
-	MessageTypeStatus    MessageType = "status"
-	MessageTypeExecuting MessageType = "executing"
-	MessageTypeProgress  MessageType = "progress"
-	MessageTypeExecuted  MessageType = "executed"
-	MessageTypeError     MessageType = "execution_error"
-	MessageTypeCached    MessageType = "execution_cached"
+	MessageTypeStatus           MessageType = "status"
+	MessageTypeExecutionStart   MessageType = "execution_start"
+	MessageTypeExecuting        MessageType = "executing"
+	MessageTypeProgress         MessageType = "progress"
+	MessageTypeExecuted         MessageType = "executed"
+	MessageTypeError            MessageType = "execution_error"
+	MessageTypeCached           MessageType = "execution_cached"
+	MessageTypeInterrupted      MessageType = "execution_interrupted"
+	MessageTypeExecutionSuccess MessageType = "execution_success"
+
+	// MessageTypeReconnected is synthesized by the SDK (not sent by ComfyUI)
+	// after a reconnecting WebSocketClient re-establishes its connection
+	MessageTypeReconnected MessageType = "reconnected"
 )
 
 // ExecutingData represents data for executing message
@@ -0,0 +1,88 @@
+package comfyui
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNodeOutputPreservesAllKinds(t *testing.T) {
+	data := []byte(`{
+		"images": [{"filename": "a.webp", "subfolder": "", "type": "output"}],
+		"animated": [true],
+		"gifs": [{"filename": "clip.mp4", "subfolder": "vhs", "type": "output", "format": "video/h264-mp4", "frame_rate": 8}],
+		"audio": [{"filename": "song.flac", "subfolder": "audio", "type": "output"}],
+		"mesh": {"vertices": 1024}
+	}`)
+
+	var output NodeOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	if len(output.Images) != 1 || output.Images[0].Filename != "a.webp" {
+		t.Errorf("Unexpected images: %+v", output.Images)
+	}
+	if animated := output.Animated(); len(animated) != 1 || !animated[0] {
+		t.Errorf("Unexpected animated flags: %v", animated)
+	}
+	gifs := output.Gifs()
+	if len(gifs) != 1 || gifs[0].Format != "video/h264-mp4" || gifs[0].FrameRate != 8 {
+		t.Errorf("Unexpected gifs: %+v", gifs)
+	}
+	if audio := output.Audio(); len(audio) != 1 || audio[0].Subfolder != "audio" {
+		t.Errorf("Unexpected audio: %+v", audio)
+	}
+	if video := output.Video(); video != nil {
+		t.Errorf("Expected no video, got %+v", video)
+	}
+	if _, ok := output.Data["mesh"]; !ok {
+		t.Error("Expected unknown key mesh to be kept in Data")
+	}
+	if _, ok := output.Data["images"]; ok {
+		t.Error("Expected images to be decoded into the typed field only")
+	}
+
+	// Round trip
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var decoded NodeOutput
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal round trip: %v", err)
+	}
+	if len(decoded.Images) != 1 || len(decoded.Gifs()) != 1 || decoded.Data["mesh"] == nil {
+		t.Errorf("Round trip lost data: %+v", decoded)
+	}
+}
+
+func TestNodeOutputNonStandardText(t *testing.T) {
+	var output NodeOutput
+	if err := json.Unmarshal([]byte(`{"text": [{"value": 1}]}`), &output); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if output.Text != nil {
+		t.Errorf("Expected typed text to be empty, got %v", output.Text)
+	}
+	if _, ok := output.Data["text"]; !ok {
+		t.Error("Expected non-string text to be kept in Data")
+	}
+}
+
+func TestHistoryItemOutputs(t *testing.T) {
+	data := []byte(`{
+		"abc": {
+			"prompt": [1, "abc", {}, {}, ["9"]],
+			"outputs": {"9": {"audio": [{"filename": "out.flac", "subfolder": "", "type": "output"}]}},
+			"status": {"status_str": "success", "completed": true, "messages": []}
+		}
+	}`)
+
+	var history History
+	if err := json.Unmarshal(data, &history); err != nil {
+		t.Fatalf("Failed to unmarshal history: %v", err)
+	}
+	if audio := history["abc"].Outputs["9"].Audio(); len(audio) != 1 || audio[0].Filename != "out.flac" {
+		t.Errorf("Unexpected audio outputs: %+v", audio)
+	}
+}
@@ -0,0 +1,360 @@
+package comfyui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Layout of exported nodes, in editor units
+const (
+	uiNodeWidth    = 315
+	uiColumnGap    = 100
+	uiRowGap       = 50
+	uiMargin       = 50
+	uiSlotHeight   = 20
+	uiWidgetHeight = 24
+	uiMinHeight    = 46
+)
+
+// ExportUIWorkflow fetches the node definitions from the server and exports
+// w to the editor format, see ExportUIWorkflow
+func (c *Client) ExportUIWorkflow(ctx context.Context, w Workflow) (*UIWorkflow, error) {
+	info, err := c.GetObjectInfo(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object info: %w", err)
+	}
+	return ExportUIWorkflow(w, info)
+}
+
+// ExportUIWorkflow converts an API format workflow to the format of the graph
+// editor, so that it can be opened and inspected there. info supplies the
+// node definitions, which give the order of widgets_values and the output
+// slots. Nodes are laid out left to right in columns by their depth in the
+// dependency graph. Numeric node IDs are kept; other IDs are renumbered.
+// Titles are taken from the nodes' metadata.
+func ExportUIWorkflow(w Workflow, info ObjectInfo) (*UIWorkflow, error) {
+	ids := uiNodeIDs(w)
+	apiIDs := make([]string, 0, len(w))
+	for id := range w {
+		apiIDs = append(apiIDs, id)
+	}
+	sort.Slice(apiIDs, func(i, j int) bool { return ids[apiIDs[i]] < ids[apiIDs[j]] })
+
+	e := &uiExporter{
+		ids:     ids,
+		nodes:   make(map[string]*UINode, len(w)),
+		origins: make(map[string][]string, len(w)),
+	}
+	ui := &UIWorkflow{
+		Nodes:   make([]UINode, 0, len(w)),
+		Links:   []UILink{},
+		Groups:  []UIGroup{},
+		Config:  map[string]interface{}{},
+		Extra:   map[string]interface{}{},
+		Version: 0.4,
+	}
+
+	for _, id := range apiIDs {
+		node := w[id]
+		class, ok := info[node.ClassType]
+		if !ok {
+			return nil, fmt.Errorf("%w: node %s has unknown class %q", ErrInvalidWorkflow, id, node.ClassType)
+		}
+		ui.Nodes = append(ui.Nodes, e.exportNode(id, node, class))
+		if ids[id] > ui.LastNodeID {
+			ui.LastNodeID = ids[id]
+		}
+	}
+	for i := range ui.Nodes {
+		e.nodes[apiIDs[i]] = &ui.Nodes[i]
+	}
+
+	links, err := e.connect()
+	if err != nil {
+		return nil, err
+	}
+	ui.Links = append(ui.Links, links...)
+	ui.LastLinkID = len(links)
+
+	if err := e.layout(apiIDs); err != nil {
+		return nil, err
+	}
+	return ui, nil
+}
+
+// SaveUIWorkflowToFile saves a workflow in the editor format to a JSON file
+func SaveUIWorkflowToFile(ui *UIWorkflow, filepath string) error {
+	data, err := json.MarshalIndent(ui, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal UI workflow: %w", err)
+	}
+
+	if err := os.WriteFile(filepath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// uiNodeIDs maps workflow node IDs to editor node IDs. Canonical positive
+// integers are kept and the remaining IDs are numbered after the largest.
+func uiNodeIDs(w Workflow) map[string]int {
+	ids := make(map[string]int, len(w))
+	var others []string
+	next := 1
+	for id := range w {
+		n, err := strconv.Atoi(id)
+		if err != nil || n <= 0 || strconv.Itoa(n) != id {
+			others = append(others, id)
+			continue
+		}
+		ids[id] = n
+		if n >= next {
+			next = n + 1
+		}
+	}
+	sort.Strings(others)
+	for _, id := range others {
+		ids[id] = next
+		next++
+	}
+	return ids
+}
+
+// nodeLink reports whether an input value is a link, ["node_id", slot], and
+// returns its parts
+func nodeLink(v interface{}) (string, int, bool) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 2 {
+		return "", 0, false
+	}
+	id, ok := arr[0].(string)
+	if !ok {
+		return "", 0, false
+	}
+	switch slot := arr[1].(type) {
+	case int:
+		return id, slot, true
+	case float64:
+		if slot == float64(int(slot)) {
+			return id, int(slot), true
+		}
+	case json.Number:
+		if n, err := slot.Int64(); err == nil {
+			return id, int(n), true
+		}
+	}
+	return "", 0, false
+}
+
+// uiExporter holds the state of an ExportUIWorkflow call
+type uiExporter struct {
+	ids     map[string]int
+	nodes   map[string]*UINode
+	pending []pendingLink
+	origins map[string][]string // node ID to the IDs of the nodes it links from
+}
+
+// pendingLink is a link recorded while exporting nodes, connected once all
+// output slots are known
+type pendingLink struct {
+	target     string
+	targetSlot int
+	origin     string
+	originSlot int
+}
+
+// exportNode builds the editor node for a workflow node, recording its links
+func (e *uiExporter) exportNode(id string, node Node, class NodeClassInfo) UINode {
+	ui := UINode{
+		ID:         e.ids[id],
+		Type:       node.ClassType,
+		Title:      node.Title(),
+		Flags:      map[string]interface{}{},
+		Mode:       UINodeModeAlways,
+		Properties: map[string]interface{}{"Node name for S&R": node.ClassType},
+	}
+
+	values := []interface{}{}
+	known := make(map[string]bool, len(node.Inputs))
+	for _, spec := range class.InputSpecs() {
+		known[spec.Name] = true
+		value, set := node.Inputs[spec.Name]
+		origin, slot, linked := nodeLink(value)
+
+		if !spec.IsWidget() {
+			if linked {
+				e.addLink(id, len(ui.Inputs), origin, slot)
+			}
+			ui.Inputs = append(ui.Inputs, UINodeInput{Name: spec.Name, Type: spec.Kind})
+			continue
+		}
+		if linked {
+			e.addLink(id, len(ui.Inputs), origin, slot)
+			ui.Inputs = append(ui.Inputs, UINodeInput{Name: spec.Name, Type: spec.Kind, Widget: &UIWidget{Name: spec.Name}})
+		}
+
+		// Widgets keep their place in widgets_values even when converted to
+		// inputs
+		if !set || linked {
+			value = spec.defaultValue()
+		}
+		values = append(values, value)
+		if spec.widgetValueCount() == 2 {
+			values = append(values, spec.widgetExtraValue())
+		}
+	}
+
+	// Links into inputs the class does not declare, e.g. from an older
+	// version of a custom node
+	for _, name := range sortedKeys(node.Inputs) {
+		if known[name] {
+			continue
+		}
+		if origin, slot, ok := nodeLink(node.Inputs[name]); ok {
+			e.addLink(id, len(ui.Inputs), origin, slot)
+			ui.Inputs = append(ui.Inputs, UINodeInput{Name: name, Type: "*"})
+		}
+	}
+
+	for i, typ := range class.Output {
+		name := typ
+		if i < len(class.OutputName) {
+			name = class.OutputName[i]
+		}
+		slot := i
+		ui.Outputs = append(ui.Outputs, UINodeOutput{Name: name, Type: typ, Links: []int{}, SlotIndex: &slot})
+	}
+
+	ui.WidgetsValues = values
+	ui.Size = UIVector{uiNodeWidth, uiNodeHeight(&ui, len(values))}
+	return ui
+}
+
+// addLink records a link into the given input slot of target
+func (e *uiExporter) addLink(target string, targetSlot int, origin string, originSlot int) {
+	e.pending = append(e.pending, pendingLink{target, targetSlot, origin, originSlot})
+	e.origins[target] = append(e.origins[target], origin)
+}
+
+// connect creates the recorded links and attaches them to their slots
+func (e *uiExporter) connect() ([]UILink, error) {
+	links := make([]UILink, 0, len(e.pending))
+	for i, p := range e.pending {
+		origin, ok := e.nodes[p.origin]
+		if !ok {
+			return nil, fmt.Errorf("%w: node %s links from missing node %s", ErrInvalidWorkflow, p.target, p.origin)
+		}
+		if p.originSlot < 0 || p.originSlot >= len(origin.Outputs) {
+			return nil, fmt.Errorf("%w: node %s links from output %d of node %s, which has %d outputs",
+				ErrInvalidWorkflow, p.target, p.originSlot, p.origin, len(origin.Outputs))
+		}
+
+		id := i + 1
+		output := &origin.Outputs[p.originSlot]
+		input := &e.nodes[p.target].Inputs[p.targetSlot]
+		if input.Type == "*" {
+			input.Type = output.Type
+		}
+		input.Link = &id
+		output.Links = append(output.Links, id)
+
+		links = append(links, UILink{
+			ID:         id,
+			OriginID:   origin.ID,
+			OriginSlot: p.originSlot,
+			TargetID:   e.ids[p.target],
+			TargetSlot: p.targetSlot,
+			Type:       output.Type,
+		})
+	}
+	return links, nil
+}
+
+// layout places the nodes in columns by their longest distance from a
+// source node and sets the execution order. Within a column nodes are
+// sorted by the mean row of the nodes they link from, which keeps links
+// mostly horizontal.
+func (e *uiExporter) layout(apiIDs []string) error {
+	depths := make(map[string]int, len(apiIDs))
+	visiting := make(map[string]bool)
+	var depth func(id string) (int, error)
+	depth = func(id string) (int, error) {
+		if d, ok := depths[id]; ok {
+			return d, nil
+		}
+		if visiting[id] {
+			return 0, fmt.Errorf("%w: node %s is part of a cycle", ErrInvalidWorkflow, id)
+		}
+		visiting[id] = true
+		d := 0
+		for _, origin := range e.origins[id] {
+			od, err := depth(origin)
+			if err != nil {
+				return 0, err
+			}
+			if od+1 > d {
+				d = od + 1
+			}
+		}
+		visiting[id] = false
+		depths[id] = d
+		return d, nil
+	}
+
+	var columns [][]string
+	for _, id := range apiIDs {
+		d, err := depth(id)
+		if err != nil {
+			return err
+		}
+		for len(columns) <= d {
+			columns = append(columns, nil)
+		}
+		columns[d] = append(columns[d], id)
+	}
+
+	rows := make(map[string]float64, len(apiIDs))
+	order := 0
+	for col, ids := range columns {
+		weight := make(map[string]float64, len(ids))
+		for _, id := range ids {
+			var sum float64
+			for _, origin := range e.origins[id] {
+				sum += rows[origin]
+			}
+			if n := len(e.origins[id]); n > 0 {
+				weight[id] = sum / float64(n)
+			}
+		}
+		sort.SliceStable(ids, func(i, j int) bool { return weight[ids[i]] < weight[ids[j]] })
+
+		y := float64(uiMargin)
+		for row, id := range ids {
+			node := e.nodes[id]
+			node.Pos = UIVector{float64(uiMargin + col*(uiNodeWidth+uiColumnGap)), y}
+			node.Order = order
+			order++
+			rows[id] = float64(row)
+			y += node.Size[1] + uiRowGap
+		}
+	}
+	return nil
+}
+
+// uiNodeHeight estimates the height the editor gives a node
+func uiNodeHeight(node *UINode, widgets int) float64 {
+	slots := len(node.Inputs)
+	if len(node.Outputs) > slots {
+		slots = len(node.Outputs)
+	}
+	height := float64(slots*uiSlotHeight + widgets*uiWidgetHeight + 10)
+	if height < uiMinHeight {
+		height = uiMinHeight
+	}
+	return height
+}
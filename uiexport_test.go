@@ -0,0 +1,219 @@
+package comfyui
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func buildTestWorkflow() Workflow {
+	wb := NewWorkflowBuilder()
+	ckpt := wb.AddNode("CheckpointLoaderSimple", map[string]interface{}{"ckpt_name": "sd15.safetensors"})
+	positive := wb.AddNode("CLIPTextEncode", map[string]interface{}{"text": "a photo of a cat"})
+	negative := wb.AddNode("CLIPTextEncode", map[string]interface{}{"text": "blurry"})
+	latent := wb.AddNode("EmptyLatentImage", map[string]interface{}{"width": 512, "height": 768, "batch_size": 1})
+	sampler := wb.AddNode("KSampler", map[string]interface{}{
+		"seed": 42, "steps": 20, "cfg": 7.5, "sampler_name": "euler", "scheduler": "normal", "denoise": 1.0,
+	})
+	decode := wb.AddNode("VAEDecode", map[string]interface{}{})
+	wb.AddNodeWithID("save", "SaveImage", map[string]interface{}{"filename_prefix": "cats"})
+	wb.workflow.SetNodeTitle(positive, "Positive Prompt")
+
+	wb.ConnectNodes(ckpt, 1, positive, "clip")
+	wb.ConnectNodes(ckpt, 1, negative, "clip")
+	wb.ConnectNodes(ckpt, 0, sampler, "model")
+	wb.ConnectNodes(positive, 0, sampler, "positive")
+	wb.ConnectNodes(negative, 0, sampler, "negative")
+	wb.ConnectNodes(latent, 0, sampler, "latent_image")
+	wb.ConnectNodes(sampler, 0, decode, "samples")
+	wb.ConnectNodes(ckpt, 2, decode, "vae")
+	wb.ConnectNodes(decode, 0, "save", "images")
+	return wb.Build()
+}
+
+// normalizeJSON decodes v's JSON encoding so that numbers compare equal
+// regardless of their Go type
+func normalizeJSON(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	return out
+}
+
+func TestExportUIWorkflowRoundTrip(t *testing.T) {
+	info := loadTestObjectInfo(t)
+	workflow := buildTestWorkflow()
+
+	ui, err := ExportUIWorkflow(workflow, info)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if len(ui.Nodes) != 7 || len(ui.Links) != 9 || ui.LastNodeID != 7 || ui.LastLinkID != 9 {
+		t.Fatalf("Unexpected graph: %d nodes, %d links, last node %d, last link %d",
+			len(ui.Nodes), len(ui.Links), ui.LastNodeID, ui.LastLinkID)
+	}
+
+	// Save and load it like the editor would
+	path := filepath.Join(t.TempDir(), "ui.json")
+	if err := SaveUIWorkflowToFile(ui, path); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	loaded, err := LoadUIWorkflowFromFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	converted, err := ConvertUIWorkflow(loaded, info)
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+
+	// The non-numeric ID is renumbered after the numeric ones
+	expected := workflow
+	expected["7"] = expected["save"]
+	delete(expected, "save")
+	expected["7"].Inputs["images"] = []interface{}{"6", 0}
+
+	if got, want := normalizeJSON(t, converted), normalizeJSON(t, expected); !reflect.DeepEqual(got, want) {
+		t.Errorf("Round trip changed the workflow:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestExportUIWorkflowWidgets(t *testing.T) {
+	workflow := Workflow{
+		"1": {ClassType: "KSampler", Inputs: map[string]interface{}{
+			"seed":  []interface{}{"2", 0},
+			"steps": 30,
+		}},
+		"2": {ClassType: "PrimitiveInt", Inputs: map[string]interface{}{"value": 7}},
+	}
+	info := loadTestObjectInfo(t)
+	info["PrimitiveInt"] = NodeClassInfo{
+		Input:  NodeInputInfo{Required: map[string]interface{}{"value": []interface{}{"INT", map[string]interface{}{"min": 0}}}},
+		Output: []string{"INT"},
+	}
+
+	ui, err := ExportUIWorkflow(workflow, info)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	sampler := ui.Nodes[0]
+
+	// Converted widgets keep a placeholder and missing widgets get defaults
+	want := []interface{}{0.0, "fixed", 30, 8.0, "euler", "normal", 1.0}
+	if !reflect.DeepEqual(sampler.WidgetsValues, want) {
+		t.Errorf("Expected widgets_values %v, got %v", want, sampler.WidgetsValues)
+	}
+
+	var seed *UINodeInput
+	for i := range sampler.Inputs {
+		if sampler.Inputs[i].Name == "seed" {
+			seed = &sampler.Inputs[i]
+		}
+	}
+	if seed == nil || seed.Widget == nil || seed.Widget.Name != "seed" || seed.Link == nil {
+		t.Fatalf("Expected seed to be a converted widget input, got %+v", seed)
+	}
+	if link := ui.Links[0]; link.ID != *seed.Link || link.OriginID != 2 || link.TargetID != 1 || link.Type != "INT" {
+		t.Errorf("Unexpected link: %+v", link)
+	}
+	if outputs := ui.Nodes[1].Outputs; len(outputs) != 1 || !reflect.DeepEqual(outputs[0].Links, []int{*seed.Link}) {
+		t.Errorf("Expected the output to list the link, got %+v", outputs)
+	}
+	if ui.Nodes[1].Pos[0] >= sampler.Pos[0] {
+		t.Errorf("Expected the source left of its target, got %v and %v", ui.Nodes[1].Pos, sampler.Pos)
+	}
+	if ui.Nodes[1].Order != 0 || sampler.Order != 1 {
+		t.Errorf("Expected execution order to follow the links, got %d and %d", ui.Nodes[1].Order, sampler.Order)
+	}
+}
+
+func TestExportUIWorkflowLayout(t *testing.T) {
+	ui, err := ExportUIWorkflow(buildTestWorkflow(), loadTestObjectInfo(t))
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	nodes := make(map[int]UINode, len(ui.Nodes))
+	for _, node := range ui.Nodes {
+		nodes[node.ID] = node
+	}
+	for _, link := range ui.Links {
+		origin, target := nodes[link.OriginID], nodes[link.TargetID]
+		if origin.Pos[0] >= target.Pos[0] {
+			t.Errorf("Link %d goes right to left: %v -> %v", link.ID, origin.Pos, target.Pos)
+		}
+		if origin.Order >= target.Order {
+			t.Errorf("Link %d: origin order %d is not before target order %d", link.ID, origin.Order, target.Order)
+		}
+	}
+
+	// Nodes in the same column do not overlap
+	for _, a := range ui.Nodes {
+		for _, b := range ui.Nodes {
+			if a.ID == b.ID || a.Pos[0] != b.Pos[0] {
+				continue
+			}
+			if a.Pos[1] < b.Pos[1] && a.Pos[1]+a.Size[1] > b.Pos[1] {
+				t.Errorf("Nodes %d and %d overlap", a.ID, b.ID)
+			}
+		}
+	}
+}
+
+func TestExportUIWorkflowErrors(t *testing.T) {
+	info := loadTestObjectInfo(t)
+
+	tests := []struct {
+		name     string
+		workflow Workflow
+		contains string
+	}{
+		{
+			name:     "unknown class",
+			workflow: Workflow{"1": {ClassType: "MissingNode"}},
+			contains: "MissingNode",
+		},
+		{
+			name: "missing origin",
+			workflow: Workflow{"1": {ClassType: "VAEDecode", Inputs: map[string]interface{}{
+				"samples": []interface{}{"9", 0},
+			}}},
+			contains: "missing node 9",
+		},
+		{
+			name: "missing output",
+			workflow: Workflow{
+				"1": {ClassType: "EmptyLatentImage", Inputs: map[string]interface{}{}},
+				"2": {ClassType: "VAEDecode", Inputs: map[string]interface{}{"samples": []interface{}{"1", 3}}},
+			},
+			contains: "output 3",
+		},
+		{
+			name: "cycle",
+			workflow: Workflow{
+				"1": {ClassType: "VAEDecode", Inputs: map[string]interface{}{"samples": []interface{}{"2", 0}}},
+				"2": {ClassType: "KSampler", Inputs: map[string]interface{}{"latent_image": []interface{}{"1", 0}}},
+			},
+			contains: "cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExportUIWorkflow(tt.workflow, info)
+			if !errors.Is(err, ErrInvalidWorkflow) || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Expected error containing %q, got %v", tt.contains, err)
+			}
+		})
+	}
+}
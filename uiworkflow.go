@@ -0,0 +1,391 @@
+package comfyui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Node modes used by the ComfyUI graph editor
+const (
+	UINodeModeAlways = 0
+	UINodeModeNever  = 2 // muted: the node is skipped and inputs linked from it are left unset
+	UINodeModeBypass = 4 // bypassed: inputs are passed through to matching outputs
+)
+
+// UIWorkflow is a workflow in the format saved by the ComfyUI graph editor,
+// as opposed to the API (prompt) format of Workflow
+type UIWorkflow struct {
+	LastNodeID int                    `json:"last_node_id"`
+	LastLinkID int                    `json:"last_link_id"`
+	Nodes      []UINode               `json:"nodes"`
+	Links      []UILink               `json:"links"`
+	Groups     []UIGroup              `json:"groups"`
+	Config     map[string]interface{} `json:"config"`
+	Extra      map[string]interface{} `json:"extra"`
+	Version    float64                `json:"version"`
+}
+
+// UINode is a node of a UIWorkflow
+type UINode struct {
+	ID            int                    `json:"id"`
+	Type          string                 `json:"type"`
+	Pos           UIVector               `json:"pos"`
+	Size          UIVector               `json:"size"`
+	Flags         map[string]interface{} `json:"flags"`
+	Order         int                    `json:"order"`
+	Mode          int                    `json:"mode"`
+	Title         string                 `json:"title,omitempty"`
+	Inputs        []UINodeInput          `json:"inputs,omitempty"`
+	Outputs       []UINodeOutput         `json:"outputs,omitempty"`
+	Properties    map[string]interface{} `json:"properties"`
+	WidgetsValues interface{}            `json:"widgets_values,omitempty"` // a list, or an object keyed by widget name
+	Color         string                 `json:"color,omitempty"`
+	BgColor       string                 `json:"bgcolor,omitempty"`
+}
+
+// UINodeInput is an input slot of a UINode
+type UINodeInput struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Link      *int      `json:"link"`
+	Widget    *UIWidget `json:"widget,omitempty"` // set for widgets converted to inputs
+	Label     string    `json:"label,omitempty"`
+	SlotIndex *int      `json:"slot_index,omitempty"`
+}
+
+// UINodeOutput is an output slot of a UINode
+type UINodeOutput struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Links     []int  `json:"links"`
+	Label     string `json:"label,omitempty"`
+	SlotIndex *int   `json:"slot_index,omitempty"`
+}
+
+// UIWidget names the widget an input slot replaces
+type UIWidget struct {
+	Name string `json:"name"`
+}
+
+// UIGroup is a titled box around nodes in the editor
+type UIGroup struct {
+	Title    string    `json:"title"`
+	Bounding []float64 `json:"bounding"`
+	Color    string    `json:"color,omitempty"`
+	FontSize float64   `json:"font_size,omitempty"`
+}
+
+// UILink connects an output slot to an input slot. It is stored as
+// [id, origin_id, origin_slot, target_id, target_slot, type].
+type UILink struct {
+	ID         int
+	OriginID   int
+	OriginSlot int
+	TargetID   int
+	TargetSlot int
+	Type       string
+}
+
+// UnmarshalJSON decodes a link from its array form, or from the object
+// form used by newer editor versions. null, which the editor can leave
+// behind for deleted links, is a no-op.
+func (l *UILink) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var obj struct {
+			ID         int         `json:"id"`
+			OriginID   int         `json:"origin_id"`
+			OriginSlot int         `json:"origin_slot"`
+			TargetID   int         `json:"target_id"`
+			TargetSlot int         `json:"target_slot"`
+			Type       interface{} `json:"type"`
+		}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		*l = UILink{obj.ID, obj.OriginID, obj.OriginSlot, obj.TargetID, obj.TargetSlot, linkType(obj.Type)}
+		return nil
+	}
+
+	var arr []interface{}
+	if err := json.Unmarshal(data, &arr); err != nil {
+		return err
+	}
+	if len(arr) < 5 {
+		return fmt.Errorf("invalid link: expected at least 5 elements, got %d", len(arr))
+	}
+	ints := make([]int, 5)
+	for i := range ints {
+		n, ok := arr[i].(float64)
+		if !ok {
+			return fmt.Errorf("invalid link: element %d is not a number", i)
+		}
+		ints[i] = int(n)
+	}
+	*l = UILink{ID: ints[0], OriginID: ints[1], OriginSlot: ints[2], TargetID: ints[3], TargetSlot: ints[4]}
+	if len(arr) > 5 {
+		l.Type = linkType(arr[5])
+	}
+	return nil
+}
+
+// MarshalJSON encodes the link in its array form
+func (l UILink) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{l.ID, l.OriginID, l.OriginSlot, l.TargetID, l.TargetSlot, l.Type})
+}
+
+// linkType converts a link type, which is usually a string, to a string
+func linkType(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// UIVector is a position or size, stored as [x, y] or {"0": x, "1": y}
+type UIVector [2]float64
+
+// UnmarshalJSON accepts both the array and the object form
+func (v *UIVector) UnmarshalJSON(data []byte) error {
+	var arr []float64
+	if err := json.Unmarshal(data, &arr); err == nil {
+		copy(v[:], arr)
+		return nil
+	}
+
+	var obj map[string]float64
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("invalid vector: %s", data)
+	}
+	v[0], v[1] = obj["0"], obj["1"]
+	return nil
+}
+
+// IsUIWorkflow reports whether data holds a workflow in the editor format
+func IsUIWorkflow(data []byte) bool {
+	var probe struct {
+		Nodes json.RawMessage `json:"nodes"`
+		Links json.RawMessage `json:"links"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(probe.Nodes), []byte("["))
+}
+
+// LoadUIWorkflowFromFile loads a workflow saved by the ComfyUI graph editor
+func LoadUIWorkflowFromFile(filepath string) (*UIWorkflow, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var ui UIWorkflow
+	if err := json.Unmarshal(data, &ui); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal UI workflow: %w", err)
+	}
+
+	return &ui, nil
+}
+
+// ConvertUIWorkflow fetches the node definitions from the server and
+// converts ui to the API format, see ConvertUIWorkflow
+func (c *Client) ConvertUIWorkflow(ctx context.Context, ui *UIWorkflow) (Workflow, error) {
+	info, err := c.GetObjectInfo(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object info: %w", err)
+	}
+	return ConvertUIWorkflow(ui, info)
+}
+
+// ConvertUIWorkflow converts a workflow saved by the graph editor to the API
+// format. info supplies the node definitions, which map widgets_values
+// positions to input names. Reroute and PrimitiveNode nodes are resolved,
+// notes and muted nodes are dropped, and bypassed nodes are passed through.
+// Node titles are kept in the nodes' metadata.
+func ConvertUIWorkflow(ui *UIWorkflow, info ObjectInfo) (Workflow, error) {
+	g := &uiGraph{
+		nodes: make(map[int]*UINode, len(ui.Nodes)),
+		links: make(map[int]UILink, len(ui.Links)),
+	}
+	for i := range ui.Nodes {
+		g.nodes[ui.Nodes[i].ID] = &ui.Nodes[i]
+	}
+	for _, link := range ui.Links {
+		if link == (UILink{}) {
+			// A deleted link
+			continue
+		}
+		g.links[link.ID] = link
+	}
+
+	workflow := make(Workflow)
+	for i := range ui.Nodes {
+		node := &ui.Nodes[i]
+		if isVirtualNode(node.Type) || node.Mode == UINodeModeNever || node.Mode == UINodeModeBypass {
+			continue
+		}
+
+		class, ok := info[node.Type]
+		if !ok {
+			return nil, fmt.Errorf("%w: node %d has unknown class %q", ErrInvalidWorkflow, node.ID, node.Type)
+		}
+
+		inputs, err := g.convertInputs(node, class)
+		if err != nil {
+			return nil, err
+		}
+		converted := Node{ClassType: node.Type, Inputs: inputs}
+		if node.Title != "" {
+			converted.Meta = &NodeMeta{Title: node.Title}
+		}
+		workflow[strconv.Itoa(node.ID)] = converted
+	}
+
+	return workflow, nil
+}
+
+// isVirtualNode reports whether a node type only exists in the editor
+func isVirtualNode(nodeType string) bool {
+	switch nodeType {
+	case "Reroute", "PrimitiveNode", "Note", "MarkdownNote":
+		return true
+	}
+	return false
+}
+
+// uiGraph indexes a UIWorkflow for conversion
+type uiGraph struct {
+	nodes map[int]*UINode
+	links map[int]UILink
+}
+
+// convertInputs builds the API inputs of node from its widget values and links
+func (g *uiGraph) convertInputs(node *UINode, class NodeClassInfo) (map[string]interface{}, error) {
+	inputs := make(map[string]interface{})
+
+	switch values := node.WidgetsValues.(type) {
+	case []interface{}:
+		i := 0
+		for _, spec := range class.InputSpecs() {
+			if !spec.IsWidget() {
+				continue
+			}
+			if i >= len(values) {
+				break
+			}
+			inputs[spec.Name] = values[i]
+			i += spec.widgetValueCount()
+		}
+	case map[string]interface{}:
+		for _, spec := range class.InputSpecs() {
+			if value, ok := values[spec.Name]; ok && spec.IsWidget() {
+				inputs[spec.Name] = value
+			}
+		}
+	}
+
+	for _, input := range node.Inputs {
+		if input.Link == nil {
+			continue
+		}
+		name := input.Name
+		if input.Widget != nil && input.Widget.Name != "" {
+			name = input.Widget.Name
+		}
+
+		value, ok, err := g.resolveLink(*input.Link, 0)
+		if err != nil {
+			return nil, fmt.Errorf("node %d input %s: %w", node.ID, name, err)
+		}
+		if ok {
+			inputs[name] = value
+		} else {
+			// The source is muted or unconnected
+			delete(inputs, name)
+		}
+	}
+
+	return inputs, nil
+}
+
+// maxLinkDepth bounds the reroute and bypass chains followed by resolveLink
+const maxLinkDepth = 100
+
+// resolveLink returns the API value for an input connected by linkID: a
+// [node_id, slot] reference, or a literal from a primitive node. It reports
+// false if the chain ends at a muted node or a dangling input.
+func (g *uiGraph) resolveLink(linkID, depth int) (interface{}, bool, error) {
+	if depth > maxLinkDepth {
+		return nil, false, fmt.Errorf("%w: link %d is part of a cycle", ErrInvalidWorkflow, linkID)
+	}
+	link, ok := g.links[linkID]
+	if !ok {
+		return nil, false, nil
+	}
+	origin, ok := g.nodes[link.OriginID]
+	if !ok {
+		return nil, false, fmt.Errorf("%w: link %d comes from missing node %d", ErrInvalidWorkflow, linkID, link.OriginID)
+	}
+
+	if origin.Mode == UINodeModeNever {
+		return nil, false, nil
+	}
+
+	switch {
+	case origin.Type == "Reroute":
+		return g.follow(origin, 0, depth)
+	case origin.Type == "PrimitiveNode":
+		values, _ := origin.WidgetsValues.([]interface{})
+		if len(values) == 0 {
+			return nil, false, nil
+		}
+		return values[0], true, nil
+	case origin.Mode == UINodeModeBypass:
+		slot := bypassInput(origin, link.OriginSlot)
+		if slot < 0 {
+			return nil, false, nil
+		}
+		return g.follow(origin, slot, depth)
+	}
+
+	return []interface{}{strconv.Itoa(origin.ID), link.OriginSlot}, true, nil
+}
+
+// follow resolves the link into the given input slot of node
+func (g *uiGraph) follow(node *UINode, slot, depth int) (interface{}, bool, error) {
+	if slot >= len(node.Inputs) || node.Inputs[slot].Link == nil {
+		return nil, false, nil
+	}
+	return g.resolveLink(*node.Inputs[slot].Link, depth+1)
+}
+
+// bypassInput returns the input slot a bypassed node passes to the given
+// output: the input at the same index if its type matches, otherwise the
+// first input of that type, or -1
+func bypassInput(node *UINode, outputSlot int) int {
+	if outputSlot >= len(node.Outputs) {
+		return -1
+	}
+	outputType := node.Outputs[outputSlot].Type
+
+	if outputSlot < len(node.Inputs) && node.Inputs[outputSlot].Type == outputType {
+		return outputSlot
+	}
+	for i, input := range node.Inputs {
+		if input.Type == outputType {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,236 @@
+package comfyui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadTestObjectInfo(t *testing.T) ObjectInfo {
+	t.Helper()
+	data, err := os.ReadFile("testdata/object_info.json")
+	if err != nil {
+		t.Fatalf("Failed to read object info: %v", err)
+	}
+	var info ObjectInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("Failed to unmarshal object info: %v", err)
+	}
+	return info
+}
+
+func loadTestUIWorkflow(t *testing.T) *UIWorkflow {
+	t.Helper()
+	ui, err := LoadUIWorkflowFromFile("testdata/ui_workflow.json")
+	if err != nil {
+		t.Fatalf("Failed to load UI workflow: %v", err)
+	}
+	return ui
+}
+
+func TestConvertUIWorkflow(t *testing.T) {
+	workflow, err := ConvertUIWorkflow(loadTestUIWorkflow(t), loadTestObjectInfo(t))
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+
+	expected := Workflow{
+		"4": {ClassType: "CheckpointLoaderSimple", Inputs: map[string]interface{}{
+			"ckpt_name": "sd15.safetensors",
+		}},
+		"6": {ClassType: "CLIPTextEncode", Inputs: map[string]interface{}{
+			"text": "a photo of a cat",
+			"clip": []interface{}{"4", 1},
+		}},
+		"7": {ClassType: "CLIPTextEncode", Inputs: map[string]interface{}{
+			"text": "blurry",
+			"clip": []interface{}{"4", 1},
+		}},
+		"5": {ClassType: "EmptyLatentImage", Inputs: map[string]interface{}{
+			"width":      float64(512),
+			"height":     float64(768),
+			"batch_size": float64(1),
+		}},
+		"3": {ClassType: "KSampler", Inputs: map[string]interface{}{
+			"model":        []interface{}{"4", 0},
+			"seed":         float64(156680208700286),
+			"steps":        float64(30),
+			"cfg":          7.5,
+			"sampler_name": "dpmpp_2m",
+			"scheduler":    "karras",
+			"positive":     []interface{}{"6", 0},
+			"negative":     []interface{}{"7", 0},
+			"latent_image": []interface{}{"5", 0},
+			"denoise":      0.9,
+		}},
+		"8": {ClassType: "VAEDecode", Inputs: map[string]interface{}{
+			"samples": []interface{}{"3", 0},
+			"vae":     []interface{}{"4", 2},
+		}},
+		"9": {ClassType: "SaveImage", Inputs: map[string]interface{}{
+			"images":          []interface{}{"8", 0},
+			"filename_prefix": "cats",
+		}},
+		"15": {ClassType: "LoadImage", Inputs: map[string]interface{}{
+			"image": "example.png",
+		}},
+	}
+
+	if len(workflow) != len(expected) {
+		t.Errorf("Expected nodes %v, got %v", len(expected), workflow.NodeIDs())
+	}
+	for id, want := range expected {
+		got, ok := workflow[id]
+		if !ok {
+			t.Errorf("Missing node %s", id)
+			continue
+		}
+		if got.ClassType != want.ClassType {
+			t.Errorf("Node %s: expected class %s, got %s", id, want.ClassType, got.ClassType)
+		}
+		if !reflect.DeepEqual(got.Inputs, want.Inputs) {
+			t.Errorf("Node %s: expected inputs %v, got %v", id, want.Inputs, got.Inputs)
+		}
+	}
+
+	if title := workflow["6"].Title(); title != "Positive Prompt" {
+		t.Errorf("Expected the node title to be kept, got %q", title)
+	}
+	if workflow["7"].Meta != nil {
+		t.Errorf("Expected no metadata for an untitled node, got %+v", workflow["7"].Meta)
+	}
+}
+
+func TestConvertUIWorkflowMutedOrigin(t *testing.T) {
+	ui := loadTestUIWorkflow(t)
+	for i := range ui.Nodes {
+		if ui.Nodes[i].ID == 5 {
+			ui.Nodes[i].Mode = UINodeModeNever
+		}
+	}
+
+	workflow, err := ConvertUIWorkflow(ui, loadTestObjectInfo(t))
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+	if _, ok := workflow["5"]; ok {
+		t.Error("Expected muted node to be dropped")
+	}
+	if _, ok := workflow["3"].Inputs["latent_image"]; ok {
+		t.Error("Expected input fed by a muted node to be dropped")
+	}
+}
+
+func TestConvertUIWorkflowErrors(t *testing.T) {
+	info := loadTestObjectInfo(t)
+
+	t.Run("unknown class", func(t *testing.T) {
+		ui := &UIWorkflow{Nodes: []UINode{{ID: 1, Type: "MissingNode"}}}
+		_, err := ConvertUIWorkflow(ui, info)
+		if !errors.Is(err, ErrInvalidWorkflow) || !strings.Contains(err.Error(), "MissingNode") {
+			t.Errorf("Expected unknown class error, got %v", err)
+		}
+	})
+
+	t.Run("reroute cycle", func(t *testing.T) {
+		one, two := 1, 2
+		ui := &UIWorkflow{
+			Nodes: []UINode{
+				{ID: 1, Type: "Reroute", Inputs: []UINodeInput{{Name: "", Type: "*", Link: &two}}},
+				{ID: 2, Type: "Reroute", Inputs: []UINodeInput{{Name: "", Type: "*", Link: &one}}},
+				{ID: 3, Type: "PreviewImage", Inputs: []UINodeInput{{Name: "images", Type: "IMAGE", Link: &one}}},
+			},
+			Links: []UILink{
+				{ID: 1, OriginID: 2, TargetID: 3, Type: "IMAGE"},
+				{ID: 2, OriginID: 1, TargetID: 2, Type: "IMAGE"},
+			},
+		}
+		_, err := ConvertUIWorkflow(ui, info)
+		if !errors.Is(err, ErrInvalidWorkflow) || !strings.Contains(err.Error(), "cycle") {
+			t.Errorf("Expected cycle error, got %v", err)
+		}
+	})
+}
+
+func TestClientConvertUIWorkflow(t *testing.T) {
+	data, err := os.ReadFile("testdata/object_info.json")
+	if err != nil {
+		t.Fatalf("Failed to read object info: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/object_info" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	workflow, err := client.ConvertUIWorkflow(context.Background(), loadTestUIWorkflow(t))
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+	if workflow["3"].Inputs["steps"] != float64(30) {
+		t.Errorf("Expected steps from the primitive node, got %v", workflow["3"].Inputs["steps"])
+	}
+}
+
+func TestUIWorkflowFormats(t *testing.T) {
+	var link UILink
+	if err := json.Unmarshal([]byte(`{"id": 7, "origin_id": 3, "origin_slot": 0, "target_id": 8, "target_slot": 0, "type": "LATENT"}`), &link); err != nil {
+		t.Fatalf("Failed to unmarshal link object: %v", err)
+	}
+	if link != (UILink{ID: 7, OriginID: 3, OriginSlot: 0, TargetID: 8, TargetSlot: 0, Type: "LATENT"}) {
+		t.Errorf("Unexpected link: %+v", link)
+	}
+	encoded, err := json.Marshal(link)
+	if err != nil {
+		t.Fatalf("Failed to marshal link: %v", err)
+	}
+	if string(encoded) != `[7,3,0,8,0,"LATENT"]` {
+		t.Errorf("Expected array form, got %s", encoded)
+	}
+
+	var withDeleted UIWorkflow
+	if err := json.Unmarshal([]byte(`{"links": [null, [1, 3, 0, 8, 0, "LATENT"]]}`), &withDeleted); err != nil {
+		t.Fatalf("Failed to unmarshal links with a deleted entry: %v", err)
+	}
+	if len(withDeleted.Links) != 2 || withDeleted.Links[0] != (UILink{}) || withDeleted.Links[1].ID != 1 {
+		t.Errorf("Unexpected links: %+v", withDeleted.Links)
+	}
+
+	var size UIVector
+	if err := json.Unmarshal([]byte(`{"0": 315, "1": 98}`), &size); err != nil {
+		t.Fatalf("Failed to unmarshal vector object: %v", err)
+	}
+	if size != (UIVector{315, 98}) {
+		t.Errorf("Unexpected vector: %v", size)
+	}
+
+	ui, err := os.ReadFile("testdata/ui_workflow.json")
+	if err != nil {
+		t.Fatalf("Failed to read UI workflow: %v", err)
+	}
+	if !IsUIWorkflow(ui) {
+		t.Error("Expected editor format to be detected")
+	}
+	if IsUIWorkflow([]byte(`{"3": {"class_type": "KSampler", "inputs": {}}}`)) {
+		t.Error("Expected API format not to be detected as editor format")
+	}
+
+	path := filepath.Join(t.TempDir(), "workflow.json")
+	if err := os.WriteFile(path, ui, 0644); err != nil {
+		t.Fatalf("Failed to write workflow: %v", err)
+	}
+	if _, err := LoadWorkflowFromFile(path); !errors.Is(err, ErrInvalidWorkflow) {
+		t.Errorf("Expected LoadWorkflowFromFile to reject the editor format, got %v", err)
+	}
+}
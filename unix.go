@@ -0,0 +1,37 @@
+package comfyui
+
+import (
+	"context"
+	"net"
+	"net/url"
+)
+
+// unixBaseURL is the URL used for requests sent over a Unix socket. The
+// host only appears in the Host header; the connection goes to the socket.
+const unixBaseURL = "http://localhost"
+
+// splitUnixBaseURL returns the socket path and the HTTP base URL for a base
+// URL such as unix:///run/comfyui.sock. Other base URLs are returned as-is.
+// Query parameters of the unix URL are kept.
+func splitUnixBaseURL(baseURL string) (socketPath, httpBaseURL string) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme != "unix" {
+		return "", baseURL
+	}
+
+	socketPath = u.Path
+	if socketPath == "" {
+		socketPath = u.Opaque
+	}
+	httpBaseURL = unixBaseURL
+	if u.RawQuery != "" {
+		httpBaseURL += "/?" + u.RawQuery
+	}
+	return socketPath, httpBaseURL
+}
+
+// dialSocket connects to the Unix socket regardless of the requested address
+func (o *clientOptions) dialSocket(ctx context.Context, _, _ string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "unix", o.socketPath)
+}
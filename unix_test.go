@@ -0,0 +1,88 @@
+package comfyui
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSplitUnixBaseURL(t *testing.T) {
+	tests := []struct {
+		baseURL, socket, http string
+	}{
+		{"unix:///run/comfyui.sock", "/run/comfyui.sock", "http://localhost"},
+		{"unix:///run/comfyui.sock?token=t", "/run/comfyui.sock", "http://localhost/?token=t"},
+		{"http://127.0.0.1:8188", "", "http://127.0.0.1:8188"},
+	}
+	for _, tt := range tests {
+		socket, httpURL := splitUnixBaseURL(tt.baseURL)
+		if socket != tt.socket || httpURL != tt.http {
+			t.Errorf("splitUnixBaseURL(%q) = %q, %q, want %q, %q", tt.baseURL, socket, httpURL, tt.socket, tt.http)
+		}
+	}
+}
+
+func TestUnixSocketTransport(t *testing.T) {
+	dir, err := os.MkdirTemp("", "comfy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "comfy.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("Unix sockets not available: %v", err)
+	}
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			conn.WriteJSON(map[string]interface{}{"type": "status", "data": map[string]interface{}{"sid": r.URL.Query().Get("clientId")}})
+			conn.ReadMessage()
+		case "/queue":
+			w.Write([]byte(`{"queue_running": [], "queue_pending": []}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client := NewClient("unix://"+socketPath, WithClientID("cid"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.GetQueue(ctx); err != nil {
+		t.Fatalf("GetQueue over Unix socket failed: %v", err)
+	}
+
+	ws, err := client.ConnectWebSocket(ctx)
+	if err != nil {
+		t.Fatalf("ConnectWebSocket over Unix socket failed: %v", err)
+	}
+	defer ws.Close()
+
+	select {
+	case msg := <-ws.Messages():
+		if msg.Type != "status" || msg.Data["sid"] != "cid" {
+			t.Errorf("Unexpected message: %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for message")
+	}
+}
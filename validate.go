@@ -0,0 +1,193 @@
+package comfyui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValidateWorkflow fetches the node definitions from the server and checks
+// w against them, see Workflow.ValidateAgainst
+func (c *Client) ValidateWorkflow(ctx context.Context, w Workflow) error {
+	info, err := c.GetObjectInfo(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to get object info: %w", err)
+	}
+	return w.ValidateAgainst(info)
+}
+
+// ValidateAgainst checks the workflow against the node definitions returned
+// by GetObjectInfo, so that mistakes are caught before the prompt is queued.
+// It reports unknown node classes, missing required and unknown inputs,
+// links to missing nodes or outputs, mismatched link types, numbers outside
+// min/max, integers off step, and combo values that are not in the list. All
+// problems are returned together as ValidationErrors; the result is nil if
+// the workflow is valid.
+func (w Workflow) ValidateAgainst(info ObjectInfo) error {
+	if len(w) == 0 {
+		return ValidationErrors{{Field: "workflow", Type: "empty_workflow", Message: "Workflow is empty"}}
+	}
+
+	var errs ValidationErrors
+	for _, id := range sortedKeys(w) {
+		errs = append(errs, w.validateNode(id, info)...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateNode checks the class and inputs of one node
+func (w Workflow) validateNode(id string, info ObjectInfo) []ValidationError {
+	node := w[id]
+	if node.ClassType == "" {
+		return []ValidationError{{NodeID: id, Field: "class_type", Type: "missing_node_type", Message: "Node has no class_type"}}
+	}
+	class, ok := info[node.ClassType]
+	if !ok {
+		return []ValidationError{{
+			NodeID:  id,
+			Type:    "missing_node_type",
+			Message: fmt.Sprintf("Node class %s does not exist", node.ClassType),
+		}}
+	}
+
+	var errs []ValidationError
+	known := make(map[string]bool, len(node.Inputs))
+	for _, spec := range class.InputSpecs() {
+		known[spec.Name] = true
+		value, ok := node.Inputs[spec.Name]
+		if !ok {
+			if !spec.Optional {
+				errs = append(errs, ValidationError{
+					NodeID:    id,
+					Field:     spec.Name,
+					Type:      "required_input_missing",
+					Message:   "Required input is missing",
+					ExtraInfo: map[string]interface{}{"input_name": spec.Name},
+				})
+			}
+			continue
+		}
+		if verr := w.validateInput(id, spec, value, info); verr != nil {
+			errs = append(errs, *verr)
+		}
+	}
+	for name := range class.Input.Hidden {
+		known[name] = true
+	}
+
+	for _, name := range sortedKeys(node.Inputs) {
+		if !known[name] {
+			errs = append(errs, ValidationError{
+				NodeID:    id,
+				Field:     name,
+				Type:      "unknown_input",
+				Message:   fmt.Sprintf("Input is not defined by %s", node.ClassType),
+				ExtraInfo: map[string]interface{}{"input_name": name},
+			})
+		}
+	}
+	return errs
+}
+
+// validateInput checks a single input value or link against its definition
+func (w Workflow) validateInput(id string, spec InputSpec, value interface{}, info ObjectInfo) *ValidationError {
+	fail := func(typ, message, details string) *ValidationError {
+		return &ValidationError{
+			NodeID:    id,
+			Field:     spec.Name,
+			Type:      typ,
+			Message:   message,
+			Details:   details,
+			ExtraInfo: map[string]interface{}{"input_name": spec.Name, "received_value": value},
+		}
+	}
+
+	if origin, slot, ok := nodeLink(value); ok {
+		originNode, ok := w[origin]
+		if !ok {
+			return fail("bad_linked_input", "Bad linked input", fmt.Sprintf("node %s does not exist", origin))
+		}
+		originClass, ok := info[originNode.ClassType]
+		if !ok {
+			// Reported for the origin node itself
+			return nil
+		}
+		if slot < 0 || slot >= len(originClass.Output) {
+			return fail("bad_linked_input", "Bad linked input",
+				fmt.Sprintf("node %s has no output %d", origin, slot))
+		}
+		if received := originClass.Output[slot]; !typesMatch(received, spec.Kind) {
+			return fail("return_type_mismatch", "Return type mismatch between linked nodes",
+				fmt.Sprintf("%s, received_type(%s) mismatch input_type(%s)", spec.Name, received, spec.Kind))
+		}
+		return nil
+	}
+
+	if err := spec.ValidateValue(value); err != nil {
+		verr := err.(*ValidationError)
+		verr.NodeID = id
+		return verr
+	}
+	return nil
+}
+
+// typesMatch reports whether an output of type received can feed an input of
+// type expected. "*" matches anything, and types may be comma-separated
+// unions such as "IMAGE,MASK", in which case every received type must be
+// accepted.
+func typesMatch(received, expected string) bool {
+	if received == "*" || expected == "*" || received == expected {
+		return true
+	}
+	accepted := make(map[string]bool)
+	for _, t := range strings.Split(expected, ",") {
+		accepted[strings.TrimSpace(t)] = true
+	}
+	for _, t := range strings.Split(received, ",") {
+		if !accepted[strings.TrimSpace(t)] {
+			return false
+		}
+	}
+	return true
+}
+
+// toFloat converts a JSON or Go number to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// sameValue compares an input value with a combo choice, treating numbers of
+// different Go types as equal
+func sameValue(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,112 @@
+package comfyui
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateAgainstValidWorkflow(t *testing.T) {
+	info := loadTestObjectInfo(t)
+	if err := buildTestWorkflow().ValidateAgainst(info); err != nil {
+		t.Errorf("Expected built workflow to be valid, got %v", err)
+	}
+
+	converted, err := ConvertUIWorkflow(loadTestUIWorkflow(t), info)
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+	if err := converted.ValidateAgainst(info); err != nil {
+		t.Errorf("Expected converted workflow to be valid, got %v", err)
+	}
+}
+
+func TestValidateAgainstReportsAllProblems(t *testing.T) {
+	workflow := buildTestWorkflow()
+	workflow.SetNodeInput("1", "ckpt_name", "missing.safetensors")
+	workflow.SetNodeInput("4", "width", 513)
+	workflow.SetNodeInput("4", "height", 0)
+	workflow.SetNodeInput("4", "batch_size", 1.5)
+	workflow.SetNodeInput("5", "cfg", 101)
+	workflow.SetNodeInput("5", "positive", []interface{}{"1", 0})
+	workflow.SetNodeInput("5", "sampler", "euler")
+	delete(workflow["5"].Inputs, "steps")
+	workflow.SetNodeInput("6", "samples", []interface{}{"42", 0})
+	workflow.SetNodeInput("6", "vae", []interface{}{"1", 3})
+	workflow.SetNodeInput("save", "filename_prefix", 7)
+	workflow.SetNodeInput("save", "prompt", map[string]interface{}{})
+	workflow.AddNode("8", "UpscaleModelLoader", nil)
+	workflow.AddNode("9", "PreviewImage", map[string]interface{}{"images": "image.png"})
+
+	err := workflow.ValidateAgainst(loadTestObjectInfo(t))
+	if !errors.Is(err, ErrInvalidWorkflow) {
+		t.Fatalf("Expected ErrInvalidWorkflow, got %v", err)
+	}
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Expected ValidationErrors, got %T", err)
+	}
+
+	var got []string
+	for _, verr := range verrs {
+		got = append(got, verr.NodeID+"/"+verr.Field+"/"+verr.Type)
+	}
+	want := []string{
+		"1/ckpt_name/value_not_in_list",
+		"4/width/value_not_multiple_of_step",
+		"4/height/value_smaller_than_min",
+		"4/batch_size/invalid_input_type",
+		"5/steps/required_input_missing",
+		"5/cfg/value_bigger_than_max",
+		"5/positive/return_type_mismatch",
+		"5/sampler/unknown_input",
+		"6/samples/bad_linked_input",
+		"6/vae/bad_linked_input",
+		"8//missing_node_type",
+		"9/images/bad_linked_input",
+		"save/filename_prefix/invalid_input_type",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected errors:\n got %v\nwant %v", got, want)
+	}
+
+	if !strings.Contains(err.Error(), "13 error(s)") || !strings.Contains(err.Error(), "received_type(MODEL) mismatch input_type(CONDITIONING)") {
+		t.Errorf("Unexpected message: %v", err)
+	}
+}
+
+func TestValidateAgainstOptionalAndEmpty(t *testing.T) {
+	info := ObjectInfo{
+		"Resize": {
+			Input: NodeInputInfo{
+				Required: map[string]interface{}{"image": []interface{}{"IMAGE"}},
+				Optional: map[string]interface{}{
+					"mask":  []interface{}{"MASK"},
+					"scale": []interface{}{"FLOAT", map[string]interface{}{"min": 0.1, "step": 0.05}},
+				},
+			},
+			Output: []string{"IMAGE"},
+		},
+		"Load": {Output: []string{"IMAGE,MASK"}},
+		"Any":  {Output: []string{"*"}},
+	}
+	workflow := Workflow{
+		"1": {ClassType: "Load"},
+		"2": {ClassType: "Any"},
+		"3": {ClassType: "Resize", Inputs: map[string]interface{}{"image": []interface{}{"2", 0}, "scale": 0.35}},
+	}
+	if err := workflow.ValidateAgainst(info); err != nil {
+		t.Errorf("Expected optional inputs and wildcard links to be valid, got %v", err)
+	}
+
+	// A union output only fits inputs accepting all of its types
+	workflow.SetNodeInput("3", "image", []interface{}{"1", 0})
+	if err := workflow.ValidateAgainst(info); err == nil {
+		t.Error("Expected IMAGE,MASK to be rejected by an IMAGE input")
+	}
+
+	if err := (Workflow{}).ValidateAgainst(info); !errors.Is(err, ErrInvalidWorkflow) {
+		t.Errorf("Expected empty workflow to be invalid, got %v", err)
+	}
+}
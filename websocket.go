@@ -2,51 +2,127 @@ package comfyui
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"net/url"
+	"log/slog"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 // WebSocketClient represents a WebSocket connection to ComfyUI
 type WebSocketClient struct {
-	conn     *websocket.Conn
-	messages chan WebSocketMessage
-	errors   chan error
-	done     chan struct{}
-	once     sync.Once
-	clientID string
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	dial      func(ctx context.Context) (*websocket.Conn, error)
+	reconnect *ReconnectPolicy
+	ctx       context.Context
+	cancel    context.CancelFunc
+	messages  chan WebSocketMessage
+	events    chan Event
+	eventOnce sync.Once
+	previews  chan PreviewImage
+	errors    chan error
+	done      chan struct{}
+	once      sync.Once
+	clientID  string
+	intercept MessageInterceptor
+	logger    *slog.Logger
+	metrics   MetricsCollector
+
+	// Last executing prompt/node, used to attribute previews without metadata
+	promptID string
+	nodeID   string
+}
+
+// ReconnectPolicy configures automatic reconnection of a WebSocketClient.
+// Backoff between attempts grows from InitialBackoff by Multiplier up to MaxBackoff.
+type ReconnectPolicy struct {
+	MaxAttempts    int // 0 means retry until the client is closed
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+// DefaultReconnectPolicy returns a policy that retries forever,
+// starting at 500ms and backing off up to 30s
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// backoff returns the delay before the given (1-based) reconnect attempt
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	return exponentialBackoff(p.InitialBackoff, p.MaxBackoff, p.Multiplier, attempt)
 }
 
 // ConnectWebSocket establishes a WebSocket connection
 func (c *Client) ConnectWebSocket(ctx context.Context) (*WebSocketClient, error) {
-	u, err := url.Parse(c.baseURL)
+	return c.connectWebSocket(ctx, c.GetClientID(), nil)
+}
+
+// ConnectWebSocketWithReconnect establishes a WebSocket connection that
+// transparently redials with the same client ID when the connection drops.
+// After each successful reconnect a synthetic MessageTypeReconnected message
+// is delivered, and the Messages channel stays open until Close is called
+// or the policy gives up.
+func (c *Client) ConnectWebSocketWithReconnect(ctx context.Context, policy ReconnectPolicy) (*WebSocketClient, error) {
+	return c.connectWebSocket(ctx, c.GetClientID(), &policy)
+}
+
+// connectWebSocket connects with clientID, which is also used for reconnects
+func (c *Client) connectWebSocket(ctx context.Context, clientID string, policy *ReconnectPolicy) (*WebSocketClient, error) {
+	wsURL, err := c.wsEndpoint(clientID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse base URL: %w", err)
+		return nil, err
 	}
 
-	// Convert http/https to ws/wss
-	scheme := "ws"
-	if u.Scheme == "https" {
-		scheme = "wss"
-	}
+	dial := func(ctx context.Context) (*websocket.Conn, error) {
+		// Authenticate on every dial so that refreshed tokens are used
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, wsURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create websocket request: %w", err)
+		}
+		if err := c.authenticate(req); err != nil {
+			return nil, err
+		}
 
-	wsURL := fmt.Sprintf("%s://%s/ws?clientId=%s", scheme, u.Host, c.clientID)
+		conn, _, err := c.dialer.DialContext(ctx, wsURL, req.Header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect websocket: %w", err)
+		}
+		return conn, nil
+	}
 
-	dialer := websocket.DefaultDialer
-	conn, _, err := dialer.DialContext(ctx, wsURL, nil)
+	logger := c.logger.With("client_id", clientID)
+	conn, err := dial(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect websocket: %w", err)
+		logger.WarnContext(ctx, "websocket connect failed", "error", err)
+		return nil, err
 	}
+	logger.InfoContext(ctx, "websocket connected", "reconnect", policy != nil)
 
+	wsCtx, cancel := context.WithCancel(context.Background())
 	ws := &WebSocketClient{
-		conn:     conn,
-		messages: make(chan WebSocketMessage, 100),
-		errors:   make(chan error, 10),
-		done:     make(chan struct{}),
-		clientID: c.clientID,
+		conn:      conn,
+		dial:      dial,
+		reconnect: policy,
+		ctx:       wsCtx,
+		cancel:    cancel,
+		messages:  make(chan WebSocketMessage, 100),
+		previews:  make(chan PreviewImage, 10),
+		errors:    make(chan error, 10),
+		done:      make(chan struct{}),
+		clientID:  clientID,
+		intercept: c.messageInterceptor,
+		logger:    logger,
+		metrics:   c.metrics,
 	}
 
 	go ws.readLoop()
@@ -57,6 +133,7 @@ func (c *Client) ConnectWebSocket(ctx context.Context) (*WebSocketClient, error)
 // readLoop reads messages from the WebSocket
 func (ws *WebSocketClient) readLoop() {
 	defer close(ws.messages)
+	defer close(ws.previews)
 	defer close(ws.errors)
 
 	for {
@@ -64,8 +141,16 @@ func (ws *WebSocketClient) readLoop() {
 		case <-ws.done:
 			return
 		default:
-			_, message, err := ws.conn.ReadMessage()
+			frameType, message, err := ws.currentConn().ReadMessage()
 			if err != nil {
+				if ws.closed() {
+					ws.logger.Debug("websocket closed")
+					return
+				}
+				ws.logger.Info("websocket disconnected", "error", err)
+				if ws.reconnect != nil && ws.redial() {
+					continue
+				}
 				select {
 				case ws.errors <- fmt.Errorf("read error: %w", err):
 				case <-ws.done:
@@ -73,29 +158,202 @@ func (ws *WebSocketClient) readLoop() {
 				return
 			}
 
-			var msg WebSocketMessage
-			if err := json.Unmarshal(message, &msg); err != nil {
+			if frameType == websocket.BinaryMessage {
+				ws.handleBinary(message)
+				continue
+			}
+
+			msg, err := decodeMessage(message)
+			if err != nil {
+				ws.logger.Warn("failed to decode websocket message", "error", err)
 				select {
 				case ws.errors <- fmt.Errorf("unmarshal error: %w", err):
 				case <-ws.done:
 				}
-				continue
+				if msg == nil {
+					continue
+				}
 			}
 
-			select {
-			case ws.messages <- msg:
-			case <-ws.done:
+			if ev, ok := msg.Event.(ExecutingEvent); ok {
+				ws.promptID = ev.PromptID
+				ws.nodeID = ""
+				if ev.Node != nil {
+					ws.nodeID = *ev.Node
+				}
+			}
+
+			ws.logger.Debug("websocket message", "type", msg.Type, "prompt_id", eventPromptID(msg.Event))
+			if !ws.deliver(*msg) {
 				return
 			}
 		}
 	}
 }
 
+// decodeMessage decodes a text frame into a message with its typed event.
+// If only the event fails to decode, the message is returned along with the
+// error, carrying an UnknownEvent.
+func decodeMessage(frame []byte) (*WebSocketMessage, error) {
+	var raw struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(frame, &raw); err != nil {
+		return nil, err
+	}
+
+	msg := &WebSocketMessage{Type: raw.Type}
+	if err := unmarshalEventData(raw.Data, &msg.Data); err != nil {
+		return nil, err
+	}
+
+	event, err := decodeEvent(raw.Type, raw.Data, msg.Data)
+	msg.Event = event
+	return msg, err
+}
+
+// handleBinary decodes a binary frame and publishes it on the previews channel.
+// Previews are best-effort: frames are dropped when the consumer falls behind,
+// and decode errors are dropped when nobody reads the Errors channel.
+func (ws *WebSocketClient) handleBinary(frame []byte) {
+	preview, err := parsePreviewFrame(frame)
+	if err != nil {
+		ws.logger.Warn("failed to decode preview frame", "error", err)
+		select {
+		case ws.errors <- err:
+		default:
+		}
+		return
+	}
+	if preview == nil {
+		return
+	}
+
+	if preview.PromptID == "" {
+		preview.PromptID = ws.promptID
+	}
+	if preview.NodeID == "" {
+		preview.NodeID = ws.nodeID
+	}
+
+	select {
+	case ws.previews <- *preview:
+	default:
+		ws.logger.Debug("dropped preview", "prompt_id", preview.PromptID, "node", preview.NodeID)
+	}
+}
+
+// deliver sends a message to the consumer, returning false once the client
+// is closed. Messages dropped by the interceptor count as delivered.
+func (ws *WebSocketClient) deliver(msg WebSocketMessage) bool {
+	if ws.intercept != nil && !ws.intercept(&msg) {
+		ws.logger.Debug("websocket message dropped by interceptor", "type", msg.Type)
+		return true
+	}
+	select {
+	case ws.messages <- msg:
+		return true
+	case <-ws.done:
+		return false
+	}
+}
+
+// redial replaces the broken connection according to the reconnect policy.
+// It returns false if the client was closed or the policy gave up.
+func (ws *WebSocketClient) redial() bool {
+	ws.currentConn().Close()
+
+	for attempt := 1; ws.reconnect.MaxAttempts <= 0 || attempt <= ws.reconnect.MaxAttempts; attempt++ {
+		timer := time.NewTimer(ws.reconnect.backoff(attempt))
+		select {
+		case <-ws.done:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+
+		conn, err := ws.dial(ws.ctx)
+		if err != nil {
+			ws.logger.Warn("websocket reconnect failed", "attempt", attempt, "error", err)
+			continue
+		}
+
+		ws.mu.Lock()
+		select {
+		case <-ws.done:
+			ws.mu.Unlock()
+			conn.Close()
+			return false
+		default:
+		}
+		ws.conn = conn
+		ws.mu.Unlock()
+
+		ws.logger.Info("websocket reconnected", "attempt", attempt)
+		if ws.metrics != nil {
+			ws.metrics.WebSocketReconnected()
+		}
+		return ws.deliver(WebSocketMessage{
+			Type: string(MessageTypeReconnected),
+			Data: map[string]interface{}{
+				"attempt": attempt,
+				"sid":     ws.clientID,
+			},
+			Event: ReconnectedEvent{Attempt: attempt, SID: ws.clientID},
+		})
+	}
+
+	ws.logger.Warn("websocket reconnect gave up", "attempts", ws.reconnect.MaxAttempts)
+	return false
+}
+
+// closed reports whether Close has been called
+func (ws *WebSocketClient) closed() bool {
+	select {
+	case <-ws.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// currentConn returns the active connection
+func (ws *WebSocketClient) currentConn() *websocket.Conn {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.conn
+}
+
 // Messages returns the channel for receiving messages
 func (ws *WebSocketClient) Messages() <-chan WebSocketMessage {
 	return ws.messages
 }
 
+// Events returns a channel of typed events. It is fed from the Messages
+// channel, so a consumer should read from either Events or Messages, not both.
+func (ws *WebSocketClient) Events() <-chan Event {
+	ws.eventOnce.Do(func() {
+		ws.events = make(chan Event, cap(ws.messages))
+		go func() {
+			defer close(ws.events)
+			for msg := range ws.messages {
+				select {
+				case ws.events <- msg.Event:
+				case <-ws.done:
+					return
+				}
+			}
+		}()
+	})
+	return ws.events
+}
+
+// Previews returns the channel for receiving live sampler preview images
+func (ws *WebSocketClient) Previews() <-chan PreviewImage {
+	return ws.previews
+}
+
 // Errors returns the channel for receiving errors
 func (ws *WebSocketClient) Errors() <-chan error {
 	return ws.errors
@@ -105,7 +363,10 @@ func (ws *WebSocketClient) Errors() <-chan error {
 func (ws *WebSocketClient) Close() error {
 	var err error
 	ws.once.Do(func() {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
 		close(ws.done)
+		ws.cancel()
 		err = ws.conn.Close()
 	})
 	return err
@@ -118,7 +379,10 @@ func (ws *WebSocketClient) SendMessage(msg interface{}) error {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	if err := ws.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+	ws.mu.Lock()
+	err = ws.conn.WriteMessage(websocket.TextMessage, data)
+	ws.mu.Unlock()
+	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 
@@ -138,17 +402,18 @@ func (ws *WebSocketClient) WaitForPromptCompletion(ctx context.Context, promptID
 				return fmt.Errorf("websocket closed")
 			}
 
-			if msg.Type == string(MessageTypeExecuting) {
-				if pid, ok := msg.Data["prompt_id"].(string); ok && pid == promptID {
-					if node, ok := msg.Data["node"].(string); !ok || node == "" {
-						return nil // Execution completed
-					}
+			switch ev := msg.Event.(type) {
+			case ExecutingEvent:
+				if ev.PromptID == promptID && ev.Done() {
+					return nil // Execution completed
 				}
-			}
-
-			if msg.Type == string(MessageTypeError) {
-				if pid, ok := msg.Data["prompt_id"].(string); ok && pid == promptID {
-					return fmt.Errorf("execution error: %v", msg.Data)
+			case ExecutionErrorEvent:
+				if ev.PromptID == promptID {
+					return newNodeError(&ev.ErrorData)
+				}
+			case ExecutionInterruptedEvent:
+				if ev.PromptID == promptID {
+					return newInterruptedError(ev.PromptID, ev.NodeID, ev.NodeType)
 				}
 			}
 		}
@@ -241,3 +506,88 @@ func (msg *WebSocketMessage) GetErrorData() (*ErrorData, error) {
 
 	return data, nil
 }
+
+// Binary event types sent by ComfyUI as the first 4 bytes of a binary frame
+const (
+	binaryEventPreviewImage             = 1
+	binaryEventText                     = 3
+	binaryEventPreviewImageWithMetadata = 4
+)
+
+// Image formats used by PREVIEW_IMAGE frames
+const (
+	previewFormatJPEG = 1
+	previewFormatPNG  = 2
+)
+
+// parsePreviewFrame decodes a binary WebSocket frame. It returns nil without
+// error for frames that are not previews (e.g. progress text).
+func parsePreviewFrame(frame []byte) (*PreviewImage, error) {
+	if len(frame) < 4 {
+		return nil, fmt.Errorf("binary frame too short: %d bytes", len(frame))
+	}
+
+	eventType := binary.BigEndian.Uint32(frame[:4])
+	payload := frame[4:]
+
+	switch eventType {
+	case binaryEventPreviewImage:
+		if len(payload) < 4 {
+			return nil, fmt.Errorf("preview frame too short: %d bytes", len(frame))
+		}
+		preview := &PreviewImage{Data: payload[4:]}
+		switch binary.BigEndian.Uint32(payload[:4]) {
+		case previewFormatJPEG:
+			preview.Format = "JPEG"
+			preview.MimeType = "image/jpeg"
+		case previewFormatPNG:
+			preview.Format = "PNG"
+			preview.MimeType = "image/png"
+		default:
+			return nil, fmt.Errorf("unknown preview image format %d", binary.BigEndian.Uint32(payload[:4]))
+		}
+		return preview, nil
+
+	case binaryEventPreviewImageWithMetadata:
+		if len(payload) < 4 {
+			return nil, fmt.Errorf("preview frame too short: %d bytes", len(frame))
+		}
+		metaLen := binary.BigEndian.Uint32(payload[:4])
+		if uint64(len(payload)-4) < uint64(metaLen) {
+			return nil, fmt.Errorf("preview metadata length %d exceeds frame size", metaLen)
+		}
+
+		var meta struct {
+			NodeID        string `json:"node_id"`
+			DisplayNodeID string `json:"display_node_id"`
+			PromptID      string `json:"prompt_id"`
+			ImageType     string `json:"image_type"`
+		}
+		if err := json.Unmarshal(payload[4:4+metaLen], &meta); err != nil {
+			return nil, fmt.Errorf("failed to decode preview metadata: %w", err)
+		}
+
+		preview := &PreviewImage{
+			MimeType:      meta.ImageType,
+			Data:          payload[4+metaLen:],
+			PromptID:      meta.PromptID,
+			NodeID:        meta.NodeID,
+			DisplayNodeID: meta.DisplayNodeID,
+		}
+		switch meta.ImageType {
+		case "image/jpeg":
+			preview.Format = "JPEG"
+		case "image/png":
+			preview.Format = "PNG"
+		case "image/webp":
+			preview.Format = "WEBP"
+		}
+		return preview, nil
+
+	case binaryEventText:
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported binary event type %d", eventType)
+	}
+}
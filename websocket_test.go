@@ -0,0 +1,276 @@
+package comfyui
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestWebSocketServer starts a server whose /ws handler is driven by handle.
+// The returned slice records the clientId of every connection.
+func newTestWebSocketServer(t *testing.T, handle func(conn *websocket.Conn, n int)) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var clientIDs []string
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		mu.Lock()
+		clientIDs = append(clientIDs, r.URL.Query().Get("clientId"))
+		n := len(clientIDs)
+		mu.Unlock()
+
+		handle(conn, n)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), clientIDs...)
+	}
+}
+
+func TestWebSocketReconnect(t *testing.T) {
+	server, clientIDs := newTestWebSocketServer(t, func(conn *websocket.Conn, n int) {
+		conn.WriteJSON(map[string]interface{}{
+			"type": "status",
+			"data": map[string]interface{}{"connection": n},
+		})
+		if n == 1 {
+			// Drop the first connection to force a reconnect
+			return
+		}
+		// Keep later connections open until the client goes away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	client := NewClient(server.URL)
+	client.SetClientID("reconnect-test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws, err := client.ConnectWebSocketWithReconnect(ctx, ReconnectPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer ws.Close()
+
+	var types []string
+	for len(types) < 3 {
+		select {
+		case msg, ok := <-ws.Messages():
+			if !ok {
+				t.Fatalf("Messages channel closed after %v", types)
+			}
+			types = append(types, msg.Type)
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for messages, got %v", types)
+		}
+	}
+
+	expected := []string{"status", string(MessageTypeReconnected), "status"}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("Expected messages %v, got %v", expected, types)
+		}
+	}
+
+	ids := clientIDs()
+	if len(ids) != 2 || ids[0] != "reconnect-test" || ids[1] != "reconnect-test" {
+		t.Errorf("Expected two connections with the same client ID, got %v", ids)
+	}
+
+	ws.Close()
+	select {
+	case _, ok := <-ws.Messages():
+		for ok {
+			_, ok = <-ws.Messages()
+		}
+	case <-time.After(time.Second):
+		t.Error("Messages channel not closed after Close")
+	}
+}
+
+func TestWebSocketWithoutReconnect(t *testing.T) {
+	server, _ := newTestWebSocketServer(t, func(conn *websocket.Conn, n int) {})
+
+	ws, err := NewClient(server.URL).ConnectWebSocket(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer ws.Close()
+
+	select {
+	case _, ok := <-ws.Messages():
+		if ok {
+			t.Error("Expected no messages")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected Messages channel to close when the server disconnects")
+	}
+}
+
+func TestReconnectPolicyBackoff(t *testing.T) {
+	policy := ReconnectPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want {
+			t.Errorf("Attempt %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+}
+
+func previewFrame(eventType uint32, header []byte, image []byte) []byte {
+	frame := make([]byte, 4, 4+len(header)+len(image))
+	binary.BigEndian.PutUint32(frame, eventType)
+	frame = append(frame, header...)
+	return append(frame, image...)
+}
+
+func TestParsePreviewFrame(t *testing.T) {
+	jpeg := []byte{0xff, 0xd8, 0xff}
+
+	format := make([]byte, 4)
+	binary.BigEndian.PutUint32(format, previewFormatJPEG)
+	preview, err := parsePreviewFrame(previewFrame(binaryEventPreviewImage, format, jpeg))
+	if err != nil {
+		t.Fatalf("Failed to parse preview: %v", err)
+	}
+	if preview.Format != "JPEG" || preview.MimeType != "image/jpeg" || !bytes.Equal(preview.Data, jpeg) {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+
+	meta := []byte(`{"node_id":"3","display_node_id":"3","prompt_id":"abc","image_type":"image/png"}`)
+	header := make([]byte, 4, 4+len(meta))
+	binary.BigEndian.PutUint32(header, uint32(len(meta)))
+	header = append(header, meta...)
+	preview, err = parsePreviewFrame(previewFrame(binaryEventPreviewImageWithMetadata, header, jpeg))
+	if err != nil {
+		t.Fatalf("Failed to parse preview with metadata: %v", err)
+	}
+	if preview.Format != "PNG" || preview.PromptID != "abc" || preview.NodeID != "3" || !bytes.Equal(preview.Data, jpeg) {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+
+	if preview, err := parsePreviewFrame(previewFrame(binaryEventText, []byte("text"), nil)); err != nil || preview != nil {
+		t.Errorf("Expected text frames to be ignored, got %+v, %v", preview, err)
+	}
+
+	if _, err := parsePreviewFrame([]byte{0, 0}); err == nil {
+		t.Error("Expected error for truncated frame")
+	}
+}
+
+func TestWebSocketPreviews(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G'}
+	server, _ := newTestWebSocketServer(t, func(conn *websocket.Conn, n int) {
+		conn.WriteJSON(map[string]interface{}{
+			"type": "executing",
+			"data": map[string]interface{}{"prompt_id": "prompt-1", "node": "3"},
+		})
+		format := make([]byte, 4)
+		binary.BigEndian.PutUint32(format, previewFormatPNG)
+		conn.WriteMessage(websocket.BinaryMessage, previewFrame(binaryEventPreviewImage, format, png))
+		conn.ReadMessage()
+	})
+
+	ws, err := NewClient(server.URL).ConnectWebSocket(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer ws.Close()
+
+	select {
+	case msg := <-ws.Messages():
+		if msg.Type != string(MessageTypeExecuting) {
+			t.Errorf("Expected executing message, got %s", msg.Type)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for executing message")
+	}
+
+	select {
+	case preview := <-ws.Previews():
+		if preview.Format != "PNG" || preview.PromptID != "prompt-1" || preview.NodeID != "3" {
+			t.Errorf("Unexpected preview: %+v", preview)
+		}
+		if !bytes.Equal(preview.Data, png) {
+			t.Errorf("Unexpected preview data: %v", preview.Data)
+		}
+	case err := <-ws.Errors():
+		t.Fatalf("Unexpected error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for preview")
+	}
+}
+
+func TestWebSocketUndecodablePreviewsDoNotBlock(t *testing.T) {
+	server, _ := newTestWebSocketServer(t, func(conn *websocket.Conn, n int) {
+		// More bad frames than the error buffer holds
+		format := make([]byte, 4)
+		binary.BigEndian.PutUint32(format, 99)
+		for i := 0; i < 12; i++ {
+			conn.WriteMessage(websocket.BinaryMessage, previewFrame(binaryEventPreviewImage, format, []byte{1}))
+		}
+		conn.WriteJSON(map[string]interface{}{
+			"type": "executing",
+			"data": map[string]interface{}{"prompt_id": "prompt-1", "node": nil},
+		})
+		conn.ReadMessage()
+	})
+
+	ws, err := NewClient(server.URL).ConnectWebSocket(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer ws.Close()
+
+	// Errors is deliberately not read
+	select {
+	case msg := <-ws.Messages():
+		if msg.Type != string(MessageTypeExecuting) {
+			t.Errorf("Expected executing message, got %s", msg.Type)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for executing message after bad previews")
+	}
+}
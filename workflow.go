@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 )
 
 // LoadWorkflowFromFile loads a workflow from a JSON file
@@ -13,6 +15,10 @@ func LoadWorkflowFromFile(filepath string) (Workflow, error) {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	if IsUIWorkflow(data) {
+		return nil, fmt.Errorf("%w: %s is in the editor format, load it with LoadUIWorkflowFromFile and convert it with ConvertUIWorkflow", ErrInvalidWorkflow, filepath)
+	}
+
 	var workflow Workflow
 	if err := json.Unmarshal(data, &workflow); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal workflow: %w", err)
@@ -121,6 +127,74 @@ func (w Workflow) NodesByClass(classType string) map[string]Node {
 	return nodes
 }
 
+// NodesByTitle returns all nodes whose _meta title is title
+func (w Workflow) NodesByTitle(title string) map[string]Node {
+	nodes := make(map[string]Node)
+	for id, node := range w {
+		if node.Title() == title {
+			nodes[id] = node
+		}
+	}
+	return nodes
+}
+
+// NodeByTitle returns the ID and node with the given _meta title. It fails
+// if no node or more than one node has that title.
+func (w Workflow) NodeByTitle(title string) (string, Node, error) {
+	nodes := w.NodesByTitle(title)
+	switch len(nodes) {
+	case 0:
+		return "", Node{}, fmt.Errorf("no node titled %q", title)
+	case 1:
+		for id, node := range nodes {
+			return id, node, nil
+		}
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return "", Node{}, fmt.Errorf("title %q is ambiguous: nodes %s", title, strings.Join(ids, ", "))
+}
+
+// SetInputByTitle sets an input value for the node with the given title
+func (w Workflow) SetInputByTitle(title string, inputName string, value interface{}) error {
+	id, _, err := w.NodeByTitle(title)
+	if err != nil {
+		return err
+	}
+	return w.SetNodeInput(id, inputName, value)
+}
+
+// GetInputByTitle gets an input value from the node with the given title
+func (w Workflow) GetInputByTitle(title string, inputName string) (interface{}, error) {
+	id, _, err := w.NodeByTitle(title)
+	if err != nil {
+		return nil, err
+	}
+	return w.GetNodeInput(id, inputName)
+}
+
+// SetNodeTitle sets the _meta title of a node
+func (w Workflow) SetNodeTitle(nodeID string, title string) error {
+	node, ok := w[nodeID]
+	if !ok {
+		return fmt.Errorf("node %s not found", nodeID)
+	}
+
+	meta := NodeMeta{}
+	if node.Meta != nil {
+		meta = *node.Meta
+	}
+	meta.Title = title
+	node.Meta = &meta
+	w[nodeID] = node
+
+	return nil
+}
+
 // Validate performs basic validation on the workflow
 func (w Workflow) Validate() error {
 	if len(w) == 0 {
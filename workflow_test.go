@@ -0,0 +1,131 @@
+package comfyui
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNodeMetadataRoundTrip(t *testing.T) {
+	data := []byte(`{
+		"6": {
+			"inputs": {"text": "a cat", "clip": ["4", 1]},
+			"class_type": "CLIPTextEncode",
+			"_meta": {"title": "Positive Prompt", "color": "#322"},
+			"is_changed": ["abc"]
+		}
+	}`)
+
+	var workflow Workflow
+	if err := json.Unmarshal(data, &workflow); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	node := workflow["6"]
+	if node.Title() != "Positive Prompt" {
+		t.Errorf("Expected title Positive Prompt, got %q", node.Title())
+	}
+	if node.Meta.Extra["color"] != "#322" {
+		t.Errorf("Expected unknown metadata to be kept, got %v", node.Meta.Extra)
+	}
+	if _, ok := node.Extra["is_changed"]; !ok {
+		t.Errorf("Expected unknown field to be kept, got %v", node.Extra)
+	}
+
+	encoded, err := json.Marshal(workflow)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var want, got interface{}
+	json.Unmarshal(data, &want)
+	json.Unmarshal(encoded, &got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Round trip changed the workflow:\n got %s\nwant %s", encoded, data)
+	}
+
+	// Untitled nodes are encoded without _meta
+	encoded, err = json.Marshal(Node{ClassType: "VAEDecode"})
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if string(encoded) != `{"class_type":"VAEDecode","inputs":null}` {
+		t.Errorf("Unexpected encoding: %s", encoded)
+	}
+}
+
+func TestPromptArrayKeepsNodeMetadata(t *testing.T) {
+	data := []byte(`[1, "p1", {"3": {"class_type": "KSampler", "inputs": {"seed": 1}, "_meta": {"title": "Sampler"}}}, {}, ["9"]]`)
+
+	var prompt PromptArray
+	if err := json.Unmarshal(data, &prompt); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if title := prompt.Workflow["3"].Title(); title != "Sampler" {
+		t.Errorf("Expected title Sampler, got %q", title)
+	}
+}
+
+func TestQueueStatusKeepsNodeMetadata(t *testing.T) {
+	data := []byte(`{
+		"queue_running": [[0, "p1", {"6": {"class_type": "CLIPTextEncode", "inputs": {"text": "a cat"}, "_meta": {"title": "Positive Prompt"}, "is_changed": ["abc"]}}, {}, ["9"]]],
+		"queue_pending": [[1, "p2", {"3": {"class_type": "KSampler", "inputs": {"seed": 1}, "_meta": {"title": "Sampler"}}}, {}, ["9"]]]
+	}`)
+
+	var queue QueueStatus
+	if err := json.Unmarshal(data, &queue); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if len(queue.QueueRunning) != 1 || len(queue.QueuePending) != 1 {
+		t.Fatalf("Unexpected queue: %+v", queue)
+	}
+
+	running := queue.QueueRunning[0].Prompt
+	id, node, err := running.NodeByTitle("Positive Prompt")
+	if err != nil || id != "6" {
+		t.Errorf("Expected running prompt lookup by title, got %q, %v", id, err)
+	}
+	if _, ok := node.Extra["is_changed"]; !ok {
+		t.Errorf("Expected unknown field to be kept, got %v", node.Extra)
+	}
+	if title := queue.QueuePending[0].Prompt["3"].Title(); title != "Sampler" {
+		t.Errorf("Expected pending prompt title Sampler, got %q", title)
+	}
+}
+
+func TestWorkflowTitleLookup(t *testing.T) {
+	workflow := Workflow{
+		"6": {ClassType: "CLIPTextEncode", Inputs: map[string]interface{}{"text": ""}, Meta: &NodeMeta{Title: "Positive Prompt"}},
+		"7": {ClassType: "CLIPTextEncode", Inputs: map[string]interface{}{"text": ""}, Meta: &NodeMeta{Title: "Negative Prompt"}},
+		"8": {ClassType: "VAEDecode"},
+	}
+
+	if err := workflow.SetInputByTitle("Positive Prompt", "text", "a lighthouse at dusk"); err != nil {
+		t.Fatalf("Failed to set input: %v", err)
+	}
+	if value, _ := workflow.GetNodeInput("6", "text"); value != "a lighthouse at dusk" {
+		t.Errorf("Expected the titled node to be updated, got %v", value)
+	}
+	if value, err := workflow.GetInputByTitle("Positive Prompt", "text"); err != nil || value != "a lighthouse at dusk" {
+		t.Errorf("Expected input by title, got %v, %v", value, err)
+	}
+	if workflow["6"].Title() != "Positive Prompt" {
+		t.Error("Expected setting an input to keep the title")
+	}
+
+	if _, _, err := workflow.NodeByTitle("Sampler"); err == nil {
+		t.Error("Expected an error for a missing title")
+	}
+
+	if err := workflow.SetNodeTitle("8", "Negative Prompt"); err != nil {
+		t.Fatalf("Failed to set title: %v", err)
+	}
+	if _, _, err := workflow.NodeByTitle("Negative Prompt"); err == nil || !strings.Contains(err.Error(), "7, 8") {
+		t.Errorf("Expected an ambiguous title error, got %v", err)
+	}
+	if nodes := workflow.NodesByTitle("Negative Prompt"); len(nodes) != 2 {
+		t.Errorf("Expected 2 nodes, got %d", len(nodes))
+	}
+	if err := workflow.SetNodeTitle("9", "Missing"); err == nil {
+		t.Error("Expected an error for a missing node")
+	}
+}